@@ -0,0 +1,164 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// sgrReset ends any styling carried by a captured line
+const sgrReset = "\x1b[0m"
+
+// captureArgs builds a tmux capture-pane invocation; with ansi_colors enabled
+// it asks for escape sequences (-e) so colors survive the capture
+func captureArgs(paneID string, extra ...string) []string {
+	args := []string{"capture-pane", "-p"}
+	if cfg.AnsiColors {
+		args = append(args, "-e")
+	}
+	args = append(args, "-t", paneID)
+	return append(args, extra...)
+}
+
+// splitStyled splits raw capture-pane output into plain and styled lines.
+// tmux carries SGR state across line breaks, so each styled line is prefixed
+// with the style still active from earlier lines and terminated with a reset,
+// making every line renderable on its own. Lines without escapes get "".
+func splitStyled(raw string) (plain, styled []string) {
+	rawLines := strings.Split(raw, "\n")
+	plain = make([]string, len(rawLines))
+	styled = make([]string, len(rawLines))
+
+	var state sgrState
+	for i, line := range rawLines {
+		carry := state.String()
+		if !strings.Contains(line, "\x1b") && carry == "" {
+			plain[i] = line
+			continue
+		}
+		plain[i] = ansi.Strip(line)
+		styled[i] = carry + line + sgrReset
+		state.apply(line)
+	}
+	return plain, styled
+}
+
+// sgrOff maps each SGR attribute code to the code that turns it off
+var sgrOff = map[int]int{1: 22, 2: 22, 3: 23, 4: 24, 21: 24, 5: 25, 6: 25, 7: 27, 8: 28, 9: 29, 53: 55}
+
+// sgrState is the styling in effect at a point of the captured output. It is
+// kept per attribute, so a later code replaces an earlier one of the same
+// kind (tmux ends a color with 39, not 0) instead of piling up.
+type sgrState struct {
+	attrs      []string // attribute params, e.g. "1" or "4:3", in the order set
+	fg, bg, ul string   // color params, e.g. "31" or "38;5;208"; "" = default
+}
+
+// apply updates the state with the SGR sequences in line
+func (st *sgrState) apply(line string) {
+	for {
+		i := strings.Index(line, "\x1b[")
+		if i < 0 {
+			return
+		}
+		line = line[i:]
+		end := strings.IndexFunc(line[2:], func(r rune) bool { return r >= 0x40 && r <= 0x7e })
+		if end < 0 {
+			return
+		}
+		seq := line[:end+3]
+		line = line[end+3:]
+		if seq[len(seq)-1] == 'm' {
+			st.applyParams(strings.Split(seq[2:len(seq)-1], ";"))
+		}
+	}
+}
+
+func (st *sgrState) applyParams(params []string) {
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		base, _, _ := strings.Cut(p, ":")
+		code, err := strconv.Atoi(base)
+		if base == "" {
+			code, err = 0, nil
+		}
+		if err != nil {
+			continue
+		}
+
+		// 38/48/58 take their color as ";5;n" or ";2;r;g;b" unless given
+		// in the colon form, which is a single param
+		if (code == 38 || code == 48 || code == 58) && !strings.Contains(p, ":") && i+1 < len(params) {
+			n := map[string]int{"5": 2, "2": 4}[params[i+1]]
+			n = min(n, len(params)-1-i)
+			p = strings.Join(params[i:i+1+n], ";")
+			i += n
+		}
+
+		switch {
+		case code == 0:
+			*st = sgrState{}
+		case code >= 30 && code <= 37, code >= 90 && code <= 97, code == 38:
+			st.fg = p
+		case code == 39:
+			st.fg = ""
+		case code >= 40 && code <= 47, code >= 100 && code <= 107, code == 48:
+			st.bg = p
+		case code == 49:
+			st.bg = ""
+		case code == 58:
+			st.ul = p
+		case code == 59:
+			st.ul = ""
+		case sgrOff[code] != 0:
+			st.setAttr(code, p)
+		default:
+			st.clearAttrs(code)
+		}
+	}
+}
+
+// setAttr turns on an attribute, replacing an earlier form of the same code
+func (st *sgrState) setAttr(code int, p string) {
+	for i, a := range st.attrs {
+		if sgrCode(a) == code {
+			st.attrs[i] = p
+			return
+		}
+	}
+	st.attrs = append(st.attrs, p)
+}
+
+// clearAttrs drops the attributes that off turns off
+func (st *sgrState) clearAttrs(off int) {
+	st.attrs = slices.DeleteFunc(st.attrs, func(a string) bool { return sgrOff[sgrCode(a)] == off })
+}
+
+// sgrCode returns the numeric code of an SGR param such as "4:3"
+func sgrCode(p string) int {
+	base, _, _ := strings.Cut(p, ":")
+	code, _ := strconv.Atoi(base)
+	return code
+}
+
+// String returns a single sequence that recreates the state, or "" when
+// nothing is styled
+func (st sgrState) String() string {
+	params := slices.Clone(st.attrs)
+	for _, c := range []string{st.fg, st.bg, st.ul} {
+		if c != "" {
+			params = append(params, c)
+		}
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+// hasStyle reports whether s contains escape sequences
+func hasStyle(s string) bool {
+	return strings.Contains(s, "\x1b")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSplitStyledCarry(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		styled []string
+	}{
+		{
+			name:   "plain lines stay unstyled",
+			raw:    "a\nb",
+			styled: []string{"", ""},
+		},
+		{
+			name:   "color carries to the next line",
+			raw:    "\x1b[31mred\nstill",
+			styled: []string{"\x1b[31mred\x1b[0m", "\x1b[31mstill\x1b[0m"},
+		},
+		{
+			name:   "default color ends the carry",
+			raw:    "\x1b[32m+a\x1b[39m\nb",
+			styled: []string{"\x1b[32m+a\x1b[39m\x1b[0m", ""},
+		},
+		{
+			name:   "reset ends the carry",
+			raw:    "\x1b[1;31mx\x1b[m\ny",
+			styled: []string{"\x1b[1;31mx\x1b[m\x1b[0m", ""},
+		},
+		{
+			name:   "later color replaces the earlier one",
+			raw:    "\x1b[31ma\x1b[32mb\nc",
+			styled: []string{"\x1b[31ma\x1b[32mb\x1b[0m", "\x1b[32mc\x1b[0m"},
+		},
+		{
+			name:   "extended colors keep their arguments",
+			raw:    "\x1b[38;5;208;48;2;1;2;3mx\ny",
+			styled: []string{"\x1b[38;5;208;48;2;1;2;3mx\x1b[0m", "\x1b[38;5;208;48;2;1;2;3my\x1b[0m"},
+		},
+		{
+			name:   "attributes merge and cancel by kind",
+			raw:    "\x1b[1m\x1b[4:3m\x1b[44ma\n\x1b[22mb\nc",
+			styled: []string{"\x1b[1m\x1b[4:3m\x1b[44ma\x1b[0m", "\x1b[1;4:3;44m\x1b[22mb\x1b[0m", "\x1b[4:3;44mc\x1b[0m"},
+		},
+		{
+			name:   "non-SGR sequences are ignored",
+			raw:    "\x1b[31mx\x1b[2K\ny",
+			styled: []string{"\x1b[31mx\x1b[2K\x1b[0m", "\x1b[31my\x1b[0m"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, styled := splitStyled(tt.raw)
+			if len(styled) != len(tt.styled) {
+				t.Fatalf("got %d lines, want %d", len(styled), len(tt.styled))
+			}
+			for i := range styled {
+				if styled[i] != tt.styled[i] {
+					t.Errorf("line %d = %q, want %q", i, styled[i], tt.styled[i])
+				}
+			}
+		})
+	}
+}
+
+// TestSplitStyledSize checks that the carried style stays bounded on long
+// colored output, such as a diff where every line sets and ends its color
+func TestSplitStyledSize(t *testing.T) {
+	var lines []string
+	for i := range 5000 {
+		switch i % 3 {
+		case 0:
+			lines = append(lines, fmt.Sprintf("\x1b[32m+added %d\x1b[39m", i))
+		case 1:
+			lines = append(lines, fmt.Sprintf("\x1b[1;3%dmcolor left open %d", i%8, i))
+		default:
+			lines = append(lines, fmt.Sprintf("\x1b[4m\x1b[48;5;%dmunderlined %d", i%256, i))
+		}
+	}
+	raw := strings.Join(lines, "\n")
+
+	_, styled := splitStyled(raw)
+	total := 0
+	for i, s := range styled {
+		if extra := len(s) - len(lines[i]); extra > 64 {
+			t.Fatalf("line %d grew by %d bytes: %q", i, extra, s)
+		}
+		total += len(s)
+	}
+	if total > 2*len(raw) {
+		t.Errorf("styled output is %d bytes for %d bytes of input", total, len(raw))
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// captureModeFollow is the mode of blocks streamed by follow mode
+const captureModeFollow = "follow"
+
+// captureBlock is one capture in the content: a header line at Start, then
+// the captured lines up to the next block's header
+type captureBlock struct {
+	Start  int       `json:"start"`            // header line
+	Pane   string    `json:"pane,omitempty"`   // pane it was captured from
+	Label  string    `json:"label,omitempty"`  // the pane's description at capture time (see paneInfo.label)
+	Time   time.Time `json:"time,omitzero"`    // when it was captured
+	Mode   string    `json:"mode,omitempty"`   // capture mode (see captureModes) or captureModeFollow
+	IPC    bool      `json:"ipc,omitempty"`    // requested over IPC rather than with a key
+	Folded bool      `json:"folded,omitempty"` // collapsed to its header line
+}
+
+// source describes where the block came from, e.g. "%4 main:1.2 pytest, 14:03:27, visible"
+func (b captureBlock) source() string {
+	s := b.Pane
+	if b.Label != "" {
+		s += " " + b.Label
+	}
+	if !b.Time.IsZero() {
+		s += ", " + b.Time.Format("15:04:05")
+	}
+	if b.Mode != "" {
+		s += ", " + b.Mode
+		if b.IPC {
+			s += " via IPC"
+		}
+	}
+	return s
+}
+
+// headerText is the header line shown above block n (1-indexed)
+func (b captureBlock) headerText(n int) string {
+	if src := b.source(); src != "" {
+		return fmt.Sprintf("─── Capture #%d · %s ───", n, src)
+	}
+	return fmt.Sprintf("─── Capture #%d ───", n)
+}
+
+// blockIndexIn returns the index of the block containing line (-1 if none)
+func blockIndexIn(blocks []captureBlock, line int) int {
+	return sort.Search(len(blocks), func(i int) bool { return blocks[i].Start > line }) - 1
+}
+
+// blockBoundsIn returns the first and last content line of block i, given
+// the total number of lines (first > last for an empty block)
+func blockBoundsIn(blocks []captureBlock, i, total int) (first, last int) {
+	last = total - 1
+	if i+1 < len(blocks) {
+		last = blocks[i+1].Start - 1
+	}
+	return blocks[i].Start + 1, last
+}
+
+// isHeaderIn reports whether line is a block header
+func isHeaderIn(blocks []captureBlock, line int) bool {
+	i := blockIndexIn(blocks, line)
+	return i >= 0 && blocks[i].Start == line
+}
+
+// blockOf returns the block a mark references, or nil
+func blockOf(blocks []captureBlock, mk Mark) *captureBlock {
+	if mk.Capture < 1 || mk.Capture > len(blocks) {
+		return nil
+	}
+	return &blocks[mk.Capture-1]
+}
+
+// markSource describes the block a mark references ("" if unknown)
+func markSource(blocks []captureBlock, mk Mark) string {
+	if b := blockOf(blocks, mk); b != nil {
+		return b.source()
+	}
+	return ""
+}
+
+func (m Model) isHeader(line int) bool {
+	return isHeaderIn(m.captures, line)
+}
+
+// blockSpan returns the first and last content line of block i
+func (m Model) blockSpan(i int) (first, last int) {
+	return blockBoundsIn(m.captures, i, len(m.lines))
+}
+
+// hidden reports whether line is inside a folded block (its header stays shown)
+func (m Model) hidden(line int) bool {
+	i := blockIndexIn(m.captures, line)
+	return i >= 0 && m.captures[i].Folded && m.captures[i].Start != line
+}
+
+// selectable reports whether the cursor may rest on line: a shown content
+// line, or the header of a folded block
+func (m Model) selectable(line int) bool {
+	if m.hidden(line) {
+		return false
+	}
+	if i := blockIndexIn(m.captures, line); i >= 0 && m.captures[i].Start == line {
+		return m.captures[i].Folded
+	}
+	return true
+}
+
+// nextShown returns the first shown line after line (len(m.lines) if none)
+func (m Model) nextShown(line int) int {
+	line++
+	for line < len(m.lines) && m.hidden(line) {
+		_, last := m.blockSpan(blockIndexIn(m.captures, line))
+		line = last + 1
+	}
+	return line
+}
+
+// prevShown returns the last shown line before line (-1 if none)
+func (m Model) prevShown(line int) int {
+	line--
+	if line >= 0 && m.hidden(line) {
+		line = m.captures[blockIndexIn(m.captures, line)].Start
+	}
+	return line
+}
+
+// shownAt returns line, or the header standing in for it when it is folded away
+func (m Model) shownAt(line int) int {
+	if m.hidden(line) {
+		return m.captures[blockIndexIn(m.captures, line)].Start
+	}
+	return line
+}
+
+// moveCursor moves the cursor by delta lines, stepping over block headers
+// and folded content; it stays put when there is no line in that direction
+func (m *Model) moveCursor(delta int) {
+	step := m.nextShown
+	if delta < 0 {
+		step, delta = m.prevShown, -delta
+	}
+	line := m.cursorLine
+	for range delta {
+		next := step(line)
+		for next >= 0 && next < len(m.lines) && !m.selectable(next) {
+			next = step(next)
+		}
+		if next < 0 || next >= len(m.lines) {
+			break
+		}
+		line = next
+	}
+	m.cursorLine = line
+}
+
+// fixCursor moves the cursor into range and onto a selectable line (down if possible)
+func (m *Model) fixCursor() {
+	m.cursorLine = max(min(m.cursorLine, len(m.lines)-1), 0)
+	m.cursorLine = m.shownAt(m.cursorLine)
+	if !m.selectable(m.cursorLine) {
+		if m.moveCursor(1); !m.selectable(m.cursorLine) {
+			m.moveCursor(-1)
+		}
+	}
+}
+
+// blockEntry returns the line the cursor lands on when entering block i
+func (m Model) blockEntry(i int) int {
+	if m.captures[i].Folded {
+		return m.captures[i].Start
+	}
+	return m.captures[i].Start + 1
+}
+
+// jumpBlock moves the cursor to the next (delta 1) or previous (delta -1)
+// block. Going back first returns to the start of the cursor's own block.
+func (m *Model) jumpBlock(delta int) {
+	if len(m.captures) == 0 {
+		return
+	}
+	i := blockIndexIn(m.captures, m.cursorLine)
+	if delta > 0 || m.cursorLine <= m.blockEntry(i) {
+		i += delta
+	}
+	if i < 0 || i >= len(m.captures) {
+		m.statusMsg = "No more captures"
+		return
+	}
+	m.cursorLine = m.blockEntry(i)
+	m.syncViewport()
+	m.statusMsg = fmt.Sprintf("Capture #%d of %d", i+1, len(m.captures))
+}
+
+// toggleFold folds or unfolds the cursor's block
+func (m *Model) toggleFold() {
+	i := blockIndexIn(m.captures, m.cursorLine)
+	if i < 0 {
+		return
+	}
+	m.captures[i].Folded = !m.captures[i].Folded
+	m.cursorLine = m.blockEntry(i)
+	m.syncViewport()
+	m.saveSession()
+}
+
+// toggleFoldAll folds every block, or unfolds them all when all are folded
+func (m *Model) toggleFoldAll() {
+	fold := slices.ContainsFunc(m.captures, func(b captureBlock) bool { return !b.Folded })
+	for i := range m.captures {
+		m.captures[i].Folded = fold
+	}
+	if i := blockIndexIn(m.captures, m.cursorLine); i >= 0 {
+		m.cursorLine = m.blockEntry(i)
+	}
+	m.syncViewport()
+	m.saveSession()
+	if fold {
+		m.statusMsg = fmt.Sprintf("Folded %d captures", len(m.captures))
+	} else {
+		m.statusMsg = "Unfolded all captures"
+	}
+}
+
+// unfoldAt unfolds the block containing line, if it is folded
+func (m *Model) unfoldAt(line int) {
+	if m.hidden(line) {
+		m.captures[blockIndexIn(m.captures, line)].Folded = false
+		m.saveSession()
+	}
+}
+
+// deleteBlock removes block i with its lines and marks. Later lines, marks
+// and blocks move up, and later blocks are renumbered.
+func (m *Model) deleteBlock(i int) {
+	start := m.captures[i].Start
+	_, last := m.blockSpan(i)
+	n := last - start + 1
+
+	var removed []Mark
+	kept := m.marks[:0]
+	for _, mk := range m.marks {
+		switch {
+		case mk.Last() < start:
+			kept = append(kept, mk)
+		case mk.Line > last:
+			mk.Line -= n
+			if mk.End > 0 {
+				mk.End -= n
+			}
+			if mk.Capture > i {
+				mk.Capture--
+			}
+			kept = append(kept, mk)
+		default:
+			removed = append(removed, mk)
+		}
+	}
+	m.marks = kept
+
+	m.lines = slices.Delete(m.lines, start, last+1)
+	if len(m.styled) > start {
+		m.styled = slices.Delete(m.styled, start, min(last+1, len(m.styled)))
+	}
+	m.captures = slices.Delete(m.captures, i, i+1)
+	for j := i; j < len(m.captures); j++ {
+		m.captures[j].Start -= n
+		m.lines[m.captures[j].Start] = m.captures[j].headerText(j + 1)
+	}
+	m.captureCount = len(m.captures)
+	if i == len(m.captures) {
+		// the last block is gone; follow mode starts a new one
+		m.followOpen = false
+	}
+
+	m.cursorLine = shiftDeleted(m.cursorLine, start, last)
+	m.scrollOffset = shiftDeleted(m.scrollOffset, start, last)
+	m.fixCursor()
+	m.refreshSearch()
+	m.syncViewport()
+	m.saveSession()
+	for _, mk := range removed {
+		publish(eventMarkRemoved, newMarkData(mk))
+	}
+}
+
+// shiftDeleted moves line to where it ends up once lines start..last are
+// deleted (lines inside the deleted range go to the line after it)
+func shiftDeleted(line, start, last int) int {
+	switch {
+	case line > last:
+		return line - (last - start + 1)
+	case line >= start:
+		return start
+	}
+	return line
+}
@@ -9,18 +9,36 @@ import (
 )
 
 func (m *Model) CopyMarksToClipboard() string {
-	text := m.ExportMarks()
+	text, err := m.ExportMarks()
+	if err != nil {
+		return fmt.Sprintf("Export failed: %v", err)
+	}
+	return m.copyExportToClipboard(m.exporter().Name(), text)
+}
+
+// copyExportToClipboard writes already-rendered export text to the clipboard
+func (m *Model) copyExportToClipboard(format, text string) string {
 	if text == "" {
 		return "No marks to export"
 	}
 	if err := clipboard.WriteAll(text); err != nil {
 		return fmt.Sprintf("Clipboard write failed: %v", err)
 	}
+	m.publishExported(format, "clipboard", text)
 	return fmt.Sprintf("Copied %d marks to clipboard", len(m.marks))
 }
 
+// publishExported notifies subscribers that marks were exported to target
+// ("clipboard" or "pane")
+func (m *Model) publishExported(format, target, text string) {
+	publish(eventExported, exportedEvent{Format: format, Target: target, Marks: len(m.marks), Text: text})
+}
+
 func (m *Model) PasteMarksToPane() string {
-	text := m.ExportMarks()
+	text, err := m.ExportMarks()
+	if err != nil {
+		return fmt.Sprintf("Export failed: %v", err)
+	}
 	if text == "" {
 		return "No marks to export"
 	}
@@ -35,5 +53,6 @@ func (m *Model) PasteMarksToPane() string {
 		}
 		return fmt.Sprintf("Failed to paste to pane: %s", errMsg)
 	}
+	m.publishExported(m.exporter().Name(), "pane", text)
 	return fmt.Sprintf("Pasted %d marks to left pane", len(m.marks))
 }
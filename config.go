@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Config holds user-tunable defaults, loaded from config.json and env overrides
+type Config struct {
+	SplitRatio       int          `json:"split_ratio"`        // content panel width percentage inside the TUI
+	PaneSplit        string       `json:"pane_split"`         // annotation pane size passed to tmux split-window -l
+	SessionName      string       `json:"session_name"`       // tmux session created by the launcher
+	SessionWidth     int          `json:"session_width"`      // initial width of the launcher's tmux session
+	SessionHeight    int          `json:"session_height"`     // initial height of the launcher's tmux session
+	NoteCharLimit    int          `json:"note_char_limit"`    // max characters in a note
+	NotePrefix       string       `json:"note_prefix"`        // prefix before notes in exports
+	ExportFormat     string       `json:"export_format"`      // initial export format
+	FollowIntervalMs int          `json:"follow_interval_ms"` // follow mode poll interval
+	AnsiColors       bool         `json:"ansi_colors"`        // keep the captured pane's colors
+	Wrap             bool         `json:"wrap"`               // start with long lines wrapped
+	CLIs             []string     `json:"clis"`               // AI CLIs to detect, in order of preference
+	Colors           ColorsConfig `json:"colors"`
+
+	// Keys rebinds actions by name, e.g. {"mark": ["m", "space"]}; see KeyMap.defs
+	Keys map[string][]string `json:"keys,omitempty"`
+}
+
+// ColorsConfig holds lipgloss colors (ANSI 256 numbers or #rrggbb)
+type ColorsConfig struct {
+	Accent   string `json:"accent"`    // borders and titles
+	Cursor   string `json:"cursor"`    // cursor line
+	Mark     string `json:"mark"`      // mark symbol
+	NoteMark string `json:"note_mark"` // mark-with-note symbol
+	Status   string `json:"status"`    // status bar and hints
+	Visual   string `json:"visual"`    // visual selection background
+	Overlay  string `json:"overlay"`   // overlay background
+	Live     string `json:"live"`      // follow mode indicator
+	Search   string `json:"search"`    // search match background
+}
+
+func defaultConfig() Config {
+	return Config{
+		SplitRatio:       70,
+		PaneSplit:        "45%",
+		SessionName:      "clipnote",
+		SessionWidth:     200,
+		SessionHeight:    50,
+		NoteCharLimit:    500,
+		NotePrefix:       "[Q] ",
+		ExportFormat:     "quote",
+		FollowIntervalMs: 1000,
+		AnsiColors:       true,
+		CLIs:             []string{"claude", "gemini", "codex", "aider"},
+		Colors: ColorsConfig{
+			Accent:   "62",
+			Cursor:   "212",
+			Mark:     "212",
+			NoteMark: "220",
+			Status:   "241",
+			Visual:   "237",
+			Overlay:  "235",
+			Live:     "203",
+			Search:   "178",
+		},
+	}
+}
+
+// cfg is the effective configuration, set once at startup by loadConfig
+var cfg = defaultConfig()
+
+// configDir returns the clipnote config directory ($XDG_CONFIG_HOME/clipnote)
+func configDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "clipnote")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "clipnote")
+}
+
+// configPath returns the config file location (CLIPNOTE_CONFIG overrides the default)
+func configPath() string {
+	if p := os.Getenv("CLIPNOTE_CONFIG"); p != "" {
+		return p
+	}
+	dir := configDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "config.json")
+}
+
+// loadConfig reads the config file over the defaults, then applies env overrides.
+// A missing file is not an error; on a broken file the defaults are kept.
+func loadConfig() (Config, error) {
+	c := defaultConfig()
+	var fileErr error
+
+	if path := configPath(); path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			fileErr = fmt.Errorf("config: %w", err)
+		default:
+			if err := json.Unmarshal(data, &c); err != nil {
+				c = defaultConfig()
+				fileErr = fmt.Errorf("config %s: %w", path, err)
+			}
+		}
+	}
+
+	envErr := c.applyEnv()
+	c.normalize()
+	return c, errors.Join(fileErr, envErr)
+}
+
+// applyEnv overrides fields from CLIPNOTE_* environment variables; colors and
+// keys have no env form and come from the config file only
+func (c *Config) applyEnv() error {
+	var errs []error
+	envInt := func(name string, dst *int) {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: not an integer: %q", name, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	envBool := func(name string, dst *bool) {
+		if v := os.Getenv(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: not a boolean: %q", name, v))
+				return
+			}
+			*dst = b
+		}
+	}
+	envStr := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+
+	envInt("CLIPNOTE_SPLIT_RATIO", &c.SplitRatio)
+	envStr("CLIPNOTE_PANE_SPLIT", &c.PaneSplit)
+	envStr("CLIPNOTE_SESSION_NAME", &c.SessionName)
+	envInt("CLIPNOTE_SESSION_WIDTH", &c.SessionWidth)
+	envInt("CLIPNOTE_SESSION_HEIGHT", &c.SessionHeight)
+	envInt("CLIPNOTE_NOTE_CHAR_LIMIT", &c.NoteCharLimit)
+	envStr("CLIPNOTE_NOTE_PREFIX", &c.NotePrefix)
+	envStr("CLIPNOTE_EXPORT_FORMAT", &c.ExportFormat)
+	envInt("CLIPNOTE_FOLLOW_INTERVAL_MS", &c.FollowIntervalMs)
+	envBool("CLIPNOTE_ANSI_COLORS", &c.AnsiColors)
+	envBool("CLIPNOTE_WRAP", &c.Wrap)
+	if v := os.Getenv("CLIPNOTE_CLIS"); v != "" {
+		c.CLIs = strings.Split(v, ",")
+	}
+	return errors.Join(errs...)
+}
+
+// normalize replaces out-of-range values with defaults
+func (c *Config) normalize() {
+	def := defaultConfig()
+	c.SplitRatio = min(max(c.SplitRatio, 30), 90)
+	if c.PaneSplit == "" {
+		c.PaneSplit = def.PaneSplit
+	}
+	if c.SessionName == "" {
+		c.SessionName = def.SessionName
+	}
+	if c.SessionWidth <= 0 {
+		c.SessionWidth = def.SessionWidth
+	}
+	if c.SessionHeight <= 0 {
+		c.SessionHeight = def.SessionHeight
+	}
+	if c.NoteCharLimit <= 0 {
+		c.NoteCharLimit = def.NoteCharLimit
+	}
+	if c.FollowIntervalMs <= 0 {
+		c.FollowIntervalMs = def.FollowIntervalMs
+	}
+	c.FollowIntervalMs = max(c.FollowIntervalMs, 200)
+	var clis []string
+	for _, cli := range c.CLIs {
+		if cli = strings.TrimSpace(cli); cli != "" {
+			clis = append(clis, cli)
+		}
+	}
+	if len(clis) == 0 {
+		clis = def.CLIs
+	}
+	c.CLIs = clis
+
+	fillColor := func(dst *string, fallback string) {
+		if *dst == "" {
+			*dst = fallback
+		}
+	}
+	fillColor(&c.Colors.Accent, def.Colors.Accent)
+	fillColor(&c.Colors.Cursor, def.Colors.Cursor)
+	fillColor(&c.Colors.Mark, def.Colors.Mark)
+	fillColor(&c.Colors.NoteMark, def.Colors.NoteMark)
+	fillColor(&c.Colors.Status, def.Colors.Status)
+	fillColor(&c.Colors.Visual, def.Colors.Visual)
+	fillColor(&c.Colors.Overlay, def.Colors.Overlay)
+	fillColor(&c.Colors.Live, def.Colors.Live)
+	fillColor(&c.Colors.Search, def.Colors.Search)
+}
+
+// tmuxColor converts a config color into tmux style syntax (numbers become colourN)
+func tmuxColor(c string) string {
+	if _, err := strconv.Atoi(c); err == nil {
+		return "colour" + c
+	}
+	return c
+}
+
+// printConfig prints the effective configuration (clipnote config)
+func printConfig(loadErr error) error {
+	path := configPath()
+	if _, err := os.Stat(path); err == nil {
+		fmt.Fprintf(os.Stderr, "# config file: %s\n", path)
+	} else {
+		fmt.Fprintf(os.Stderr, "# config file: %s (not found, using defaults)\n", path)
+	}
+	if loadErr != nil {
+		fmt.Fprintf(os.Stderr, "# warning: %v\n", loadErr)
+	}
+
+	effective := cfg
+	effective.Keys = keys.bindingsConfig()
+	data, err := json.MarshalIndent(effective, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"slices"
+	"sync"
+)
+
+// event names pushed to subscribe connections
+const (
+	eventMarkAdded   = "mark-added"
+	eventMarkRemoved = "mark-removed"
+	eventNoteChanged = "note-changed"
+	eventCaptured    = "captured"
+	eventExported    = "exported"
+	eventCleared     = "cleared"
+)
+
+var eventNames = []string{eventMarkAdded, eventMarkRemoved, eventNoteChanged, eventCaptured, eventExported, eventCleared}
+
+// eventOverflow tells a subscriber that events were dropped for it, so its
+// view of the marks may be stale. It is sent whatever events were asked for.
+const eventOverflow = "overflow"
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before further events are dropped for it
+const subscriberBuffer = 256
+
+// ipcEvent is one NDJSON line pushed to subscribers
+type ipcEvent struct {
+	V     int    `json:"v"`
+	Type  string `json:"type"` // always "event"
+	Event string `json:"event"`
+	Data  any    `json:"data,omitempty"`
+}
+
+type subscriber struct {
+	ch       chan ipcEvent
+	events   []string      // event names to deliver (empty = all)
+	missed   int           // events dropped since the last overflow event (guarded by subscribers)
+	overflow chan struct{} // signalled when an event is dropped
+}
+
+// subscribers holds the open subscribe connections
+var subscribers struct {
+	sync.Mutex
+	list []*subscriber
+}
+
+// subscribe registers a subscriber for the given events (all when empty)
+func subscribe(events []string) *subscriber {
+	sub := &subscriber{ch: make(chan ipcEvent, subscriberBuffer), events: events, overflow: make(chan struct{}, 1)}
+	subscribers.Lock()
+	subscribers.list = append(subscribers.list, sub)
+	subscribers.Unlock()
+	return sub
+}
+
+func unsubscribe(sub *subscriber) {
+	subscribers.Lock()
+	defer subscribers.Unlock()
+	if i := slices.Index(subscribers.list, sub); i >= 0 {
+		subscribers.list = slices.Delete(subscribers.list, i, i+1)
+	}
+}
+
+// publish sends an event to every interested subscriber. It never blocks the
+// bubbletea loop: a subscriber whose buffer is full misses the event, which is
+// counted and reported to it with an overflow event.
+func publish(event string, data any) {
+	subscribers.Lock()
+	defer subscribers.Unlock()
+	for _, sub := range subscribers.list {
+		if len(sub.events) > 0 && !slices.Contains(sub.events, event) {
+			continue
+		}
+		select {
+		case sub.ch <- ipcEvent{V: ipcProtocolVersion, Type: "event", Event: event, Data: data}:
+		default:
+			sub.missed++
+			select {
+			case sub.overflow <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// takeMissed returns and resets the number of events dropped for sub
+func (sub *subscriber) takeMissed() int {
+	subscribers.Lock()
+	defer subscribers.Unlock()
+	n := sub.missed
+	sub.missed = 0
+	return n
+}
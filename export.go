@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Exporter renders marks into the text that is copied or pasted back to the AI CLI.
+// lines is the full captured content and blocks its capture blocks, for
+// exporters that include surrounding context or where a mark came from.
+type Exporter interface {
+	Name() string
+	Export(marks []Mark, lines []string, blocks []captureBlock) (string, error)
+}
+
+// exporters lists the built-in formats in the order the TUI cycles through them
+var exporters = []Exporter{
+	quoteExporter{},
+	markdownExporter{},
+	reviewExporter{},
+	jsonExporter{},
+	xmlExporter{},
+}
+
+// findExporter looks up an exporter by name
+func findExporter(name string) (Exporter, bool) {
+	for _, e := range exporters {
+		if e.Name() == name {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// exporterNames returns the names of all available formats
+func exporterNames() []string {
+	names := make([]string, len(exporters))
+	for i, e := range exporters {
+		names[i] = e.Name()
+	}
+	return names
+}
+
+// exporter returns the currently selected exporter, falling back to the default
+func (m *Model) exporter() Exporter {
+	if e, ok := findExporter(m.exportFormat); ok {
+		return e
+	}
+	return exporters[0]
+}
+
+// cycleExportFormat switches to the next export format and returns its name
+func (m *Model) cycleExportFormat() string {
+	current := m.exporter().Name()
+	for i, e := range exporters {
+		if e.Name() == current {
+			m.exportFormat = exporters[(i+1)%len(exporters)].Name()
+			break
+		}
+	}
+	return m.exportFormat
+}
+
+// quoteExporter is the original layout: marked text, then "> [Q] note"
+type quoteExporter struct{}
+
+func (quoteExporter) Name() string { return "quote" }
+
+func (quoteExporter) Export(marks []Mark, _ []string, _ []captureBlock) (string, error) {
+	var sb strings.Builder
+	for _, mk := range marks {
+		sb.WriteString(mk.Text + "\n")
+		if mk.Note != "" {
+			sb.WriteString(fmt.Sprintf("> %s%s\n", cfg.NotePrefix, mk.Note))
+		}
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// markdownExporter puts each mark in a fenced code block headed by its line
+// label and the capture it came from
+type markdownExporter struct{}
+
+func (markdownExporter) Name() string { return "markdown" }
+
+func (markdownExporter) Export(marks []Mark, _ []string, blocks []captureBlock) (string, error) {
+	var sb strings.Builder
+	for i, mk := range marks {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		if src := markSource(blocks, mk); src != "" {
+			sb.WriteString(fmt.Sprintf("**%s** (capture #%d: %s)\n", mk.Label(), mk.Capture, src))
+		} else {
+			sb.WriteString(fmt.Sprintf("**%s**\n", mk.Label()))
+		}
+		fence := codeFence(mk.Text)
+		sb.WriteString(fence + "\n" + mk.Text + "\n" + fence + "\n")
+		if mk.Note != "" {
+			sb.WriteString(fmt.Sprintf("> %s%s\n", cfg.NotePrefix, mk.Note))
+		}
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// codeFence returns a backtick fence longer than any backtick run inside text
+func codeFence(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// reviewExporter prefixes every line with "L<n>:" like a code review comment,
+// with a "# Capture #N: <source>" line whenever the capture changes
+type reviewExporter struct{}
+
+func (reviewExporter) Name() string { return "review" }
+
+func (reviewExporter) Export(marks []Mark, _ []string, blocks []captureBlock) (string, error) {
+	var sb strings.Builder
+	capture := 0
+	for i, mk := range marks {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		if src := markSource(blocks, mk); src != "" && mk.Capture != capture {
+			sb.WriteString(fmt.Sprintf("# Capture #%d: %s\n", mk.Capture, src))
+			capture = mk.Capture
+		}
+		for j, line := range strings.Split(mk.Text, "\n") {
+			sb.WriteString(fmt.Sprintf("L%d: %s\n", mk.Line+j+1, line))
+		}
+		if mk.Note != "" {
+			sb.WriteString(fmt.Sprintf("  %s%s\n", cfg.NotePrefix, mk.Note))
+		}
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// exportedMark is the JSON shape of a mark (1-indexed lines, as shown in the TUI)
+type exportedMark struct {
+	Line    int    `json:"line"`
+	End     int    `json:"end,omitempty"`
+	Text    string `json:"text"`
+	Note    string `json:"note,omitempty"`
+	Capture int    `json:"capture,omitempty"`
+	Pane    string `json:"pane,omitempty"`
+	Source  string `json:"source,omitempty"` // the capture's pane, time and mode (see captureBlock.source)
+}
+
+// jsonExporter emits the marks as an indented JSON array
+type jsonExporter struct{}
+
+func (jsonExporter) Name() string { return "json" }
+
+func (jsonExporter) Export(marks []Mark, _ []string, blocks []captureBlock) (string, error) {
+	out := make([]exportedMark, len(marks))
+	for i, mk := range marks {
+		out[i] = exportedMark{Line: mk.Line + 1, Text: mk.Text, Note: mk.Note, Capture: mk.Capture, Pane: mk.Pane,
+			Source: markSource(blocks, mk)}
+		if mk.IsRange() {
+			out[i].End = mk.Last() + 1
+		}
+	}
+	// keep <, > and & literal so code snippets stay readable
+	var sb strings.Builder
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// xmlExporter wraps each mark in compact tags that AI CLIs parse reliably
+type xmlExporter struct{}
+
+func (xmlExporter) Name() string { return "xml" }
+
+func (xmlExporter) Export(marks []Mark, _ []string, blocks []captureBlock) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("<marks>\n")
+	for _, mk := range marks {
+		lines := fmt.Sprintf("%d", mk.Line+1)
+		if mk.IsRange() {
+			lines = fmt.Sprintf("%d-%d", mk.Line+1, mk.Last()+1)
+		}
+		source := ""
+		if src := markSource(blocks, mk); src != "" {
+			source = fmt.Sprintf(" capture=\"%d\" source=\"%s\"", mk.Capture, xmlAttrEscaper.Replace(src))
+		}
+		sb.WriteString(fmt.Sprintf("<mark lines=\"%s\"%s>\n<text>%s</text>\n", lines, source, xmlEscape(mk.Text)))
+		if mk.Note != "" {
+			sb.WriteString(fmt.Sprintf("<note>%s</note>\n", xmlEscape(mk.Note)))
+		}
+		sb.WriteString("</mark>\n")
+	}
+	sb.WriteString("</marks>")
+	return sb.String(), nil
+}
+
+// xmlEscaper escapes markup characters but keeps newlines readable
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// xmlAttrEscaper also escapes quotes, for attribute values
+var xmlAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+func xmlEscape(s string) string {
+	return xmlEscaper.Replace(s)
+}
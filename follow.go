@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// followTickMsg triggers the next follow-mode poll.
+// gen ties the tick to one follow session so toggling off/on never runs two loops.
+type followTickMsg struct{ gen int }
+
+func followTick(gen int) tea.Cmd {
+	interval := time.Duration(cfg.FollowIntervalMs) * time.Millisecond
+	return tea.Tick(interval, func(time.Time) tea.Msg {
+		return followTickMsg{gen: gen}
+	})
+}
+
+// captureFollow runs an incremental capture tagged as a follow-mode poll
+func captureFollow(paneID string, tail *paneTail, gen int) tea.Cmd {
+	return func() tea.Msg {
+		msg := capturePane(paneID, captureSpec{Mode: captureModeNew}, tail)
+		msg.FollowGen = gen
+		return msg
+	}
+}
+
+// toggleFollow starts or stops streaming new pane output into the panel
+func (m Model) toggleFollow() (Model, tea.Cmd) {
+	if m.following {
+		m.following = false
+		m.statusMsg = "Follow mode off"
+		return m, nil
+	}
+	m.following = true
+	m.followGen++
+	m.followOpen = false
+	m.statusMsg = "Follow mode on"
+	return m, captureFollow(m.sourcePane(), m.sourceTail(), m.followGen)
+}
+
+// handleFollowTick polls the pane unless follow mode is paused for note input
+func (m Model) handleFollowTick(msg followTickMsg) (tea.Model, tea.Cmd) {
+	if !m.following || msg.gen != m.followGen {
+		return m, nil
+	}
+	if m.followPaused() {
+		return m, followTick(m.followGen)
+	}
+	return m, captureFollow(m.sourcePane(), m.sourceTail(), m.followGen)
+}
+
+// followPaused reports whether polling is suspended (while typing a note)
+func (m Model) followPaused() bool {
+	return m.inputMode
+}
+
+// handleFollowCapture appends a follow-mode poll result. Consecutive polls
+// extend one capture block; the cursor sticks to the bottom only if it was there.
+func (m Model) handleFollowCapture(msg CaptureAppendMsg) (tea.Model, tea.Cmd) {
+	if !m.following || msg.FollowGen != m.followGen {
+		return m, nil
+	}
+	next := followTick(m.followGen)
+
+	if msg.Failed {
+		m.statusMsg = strings.ReplaceAll(msg.Content, "\n", " ")
+		return m, next
+	}
+	m.setTail(msg.Pane, msg.Tail)
+	if msg.Content == "" {
+		return m, next
+	}
+
+	atBottom := len(m.lines) == 0 || m.cursorLine >= len(m.lines)-1
+	cursor, status := m.cursorLine, m.statusMsg
+	if m.followOpen {
+		newLines := strings.Split(msg.Content, "\n")
+		m.appendLines(newLines, msg.Styled)
+		m.publishCaptured(len(newLines))
+	} else {
+		m = m.handleCaptureAppend(msg)
+		m.followOpen = true
+	}
+
+	if atBottom && !m.cursorHeld() {
+		m.cursorLine = len(m.lines) - 1
+	} else {
+		m.cursorLine = cursor
+	}
+	m.fixCursor()
+	m.statusMsg = status
+	m.syncViewport()
+	m.saveSession()
+	return m, next
+}
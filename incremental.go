@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// captureTailLines is how many trailing lines are remembered to re-find
+// the previous capture position
+const captureTailLines = 5
+
+// captureDiffWindow bounds how far back the fallback diff searches for the tail
+const captureDiffWindow = 500
+
+// paneTail remembers where the previous capture ended in the watched pane.
+// The cursor row itself is excluded: it is usually a prompt that keeps changing.
+type paneTail struct {
+	Abs   int      `json:"abs"`   // absolute line (history_size + cursor_y - 1) of the last finished line
+	Lines []string `json:"lines"` // last few lines up to Abs, to verify the position still matches
+}
+
+// panePosition returns the pane's history size and cursor row
+func panePosition(paneID string) (historySize, cursorY int, ok bool) {
+	out, err := execCommand("tmux", "display-message",
+		"-t", paneID, "-p", "#{history_size} #{cursor_y}").Output()
+	if err != nil {
+		return 0, 0, false
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return 0, 0, false
+	}
+	hs, err1 := strconv.Atoi(fields[0])
+	cy, err2 := strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	return hs, cy, true
+}
+
+// captureLines captures pane lines start..end (tmux coordinates: 0 = top of
+// the visible area, negative = history) without trimming.
+// Returns plain lines (for comparison) and their styled counterparts.
+func captureLines(paneID string, start, end int) (plain, styled []string, err error) {
+	out, err := execCommand("tmux", captureArgs(paneID,
+		"-S", strconv.Itoa(start), "-E", strconv.Itoa(end))...).CombinedOutput()
+	if err != nil {
+		errMsg := strings.TrimSpace(string(out))
+		if errMsg == "" {
+			errMsg = err.Error()
+		}
+		return nil, nil, fmt.Errorf("%s", errMsg)
+	}
+	plain, styled = splitStyled(strings.TrimSuffix(string(out), "\n"))
+	return plain, styled, nil
+}
+
+// newTail builds the tail for lines ending at absolute line abs
+func newTail(abs int, lines []string) *paneTail {
+	k := min(captureTailLines, len(lines))
+	return &paneTail{Abs: abs, Lines: slices.Clone(lines[len(lines)-k:])}
+}
+
+// readTail records the pane's current end position (attached to every capture)
+func readTail(paneID string) *paneTail {
+	hs, cy, ok := panePosition(paneID)
+	if !ok {
+		return nil
+	}
+	lines, _, err := captureLines(paneID, cy-captureTailLines, cy-1)
+	if err != nil {
+		return nil
+	}
+	return newTail(hs+cy-1, lines)
+}
+
+// captureNew appends only the output produced since the previous capture
+// (the visible area when there was none)
+func captureNew(paneID string, tail *paneTail) tea.Cmd {
+	return captureCmd(paneID, captureSpec{Mode: captureModeNew}, tail)
+}
+
+// captureNewOutput re-reads the pane from the remembered tail position; if the
+// tail no longer matches there (pane cleared, history trimmed, screen redrawn)
+// it falls back to searching recent output for the tail lines.
+func captureNewOutput(paneID string, tail *paneTail) CaptureAppendMsg {
+	hs, cy, ok := panePosition(paneID)
+	if !ok {
+		return CaptureAppendMsg{Content: "Capture failed:\ncannot read pane position", Failed: true}
+	}
+	abs := hs + cy - 1
+	end := cy - 1
+
+	if abs >= tail.Abs {
+		start := tail.Abs - len(tail.Lines) + 1 - hs
+		lines, styled, err := captureLines(paneID, start, end)
+		if k := len(tail.Lines); err == nil && len(lines) >= k && slices.Equal(lines[:k], tail.Lines) {
+			return incrementalMsg(lines[k:], styled[k:], newTail(abs, lines))
+		}
+	}
+
+	// fallback: diff recent output against the remembered tail
+	lines, styled, err := captureLines(paneID, max(end-captureDiffWindow+1, -hs), end)
+	if err != nil {
+		return CaptureAppendMsg{Content: "Capture failed:\n" + err.Error(), Failed: true}
+	}
+	from, found := tailEnd(lines, tail.Lines)
+	if !found {
+		// tail is gone entirely: treat the visible area as new
+		from = max(len(lines)-cy, 0)
+	}
+	return incrementalMsg(lines[from:], styled[from:], newTail(abs, lines))
+}
+
+// tailEnd finds the last occurrence of tail (or its longest matching suffix)
+// in lines and returns the index just after it
+func tailEnd(lines, tail []string) (int, bool) {
+	for n := len(tail); n >= 1; n-- {
+		suffix := tail[len(tail)-n:]
+		if blankLines(suffix) {
+			continue
+		}
+		for i := len(lines) - n; i >= 0; i-- {
+			if slices.Equal(lines[i:i+n], suffix) {
+				return i + n, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func blankLines(lines []string) bool {
+	for _, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func incrementalMsg(fresh, styled []string, tail *paneTail) CaptureAppendMsg {
+	// drop blank lines between the last output and the cursor
+	for len(fresh) > 0 && strings.TrimSpace(fresh[len(fresh)-1]) == "" {
+		fresh = fresh[:len(fresh)-1]
+	}
+	return CaptureAppendMsg{
+		Content:     strings.Join(fresh, "\n"),
+		Styled:      styled[:len(fresh)],
+		Tail:        tail,
+		Incremental: true,
+	}
+}
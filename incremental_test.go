@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTailEnd(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		tail  []string
+		want  int
+		found bool
+	}{
+		{"tail at the end", []string{"a", "b", "c"}, []string{"b", "c"}, 3, true},
+		{"new lines after the tail", []string{"a", "b", "c", "d"}, []string{"a", "b"}, 2, true},
+		{"last occurrence wins", []string{"x", "y", "z", "x", "y"}, []string{"x", "y"}, 5, true},
+		{"only a suffix survived", []string{"c", "d", "e"}, []string{"a", "b", "c"}, 1, true},
+		{"blank suffixes are not matched", []string{"", "q"}, []string{"a", ""}, 0, false},
+		{"blank lines within the tail still match", []string{"a", "", "b", "n"}, []string{"a", "", "b"}, 3, true},
+		{"tail gone", []string{"p", "q"}, []string{"a", "b"}, 0, false},
+		{"no lines", nil, []string{"a"}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := tailEnd(tt.lines, tt.tail)
+			if got != tt.want || found != tt.found {
+				t.Errorf("tailEnd = %d, %v; want %d, %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+// fakePane is the state of a tmux pane served by the fake tmux helper
+type fakePane struct {
+	Lines   []string // history followed by the visible area, as printed with -e
+	History int      // history_size: how many of Lines are above the visible area
+	CursorY int      // cursor_y: cursor row within the visible area
+}
+
+// useFakePane makes execCommand run the test binary as a tmux that serves p
+func useFakePane(t *testing.T, p fakePane) {
+	t.Helper()
+	state, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := execCommand
+	t.Cleanup(func() { execCommand = old })
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		cmd := exec.Command(os.Args[0], append([]string{"-test.run=^TestFakeTmux$", "--"}, args...)...)
+		cmd.Env = append(os.Environ(), "CLIPNOTE_FAKE_PANE="+string(state))
+		return cmd
+	}
+}
+
+// TestFakeTmux is not a real test: it is the tmux process started by
+// useFakePane, answering the display-message and capture-pane calls
+func TestFakeTmux(t *testing.T) {
+	state := os.Getenv("CLIPNOTE_FAKE_PANE")
+	if state == "" {
+		return
+	}
+	var p fakePane
+	json.Unmarshal([]byte(state), &p)
+	args := os.Args[slices.Index(os.Args, "--")+1:]
+
+	switch args[0] {
+	case "display-message":
+		fmt.Printf("%d %d\n", p.History, p.CursorY)
+	case "capture-pane":
+		// tmux clamps the range to the pane and accepts it in either order
+		start, end := -p.History, len(p.Lines)-p.History-1
+		if i := slices.Index(args, "-S"); i >= 0 {
+			start, _ = strconv.Atoi(args[i+1])
+		}
+		if i := slices.Index(args, "-E"); i >= 0 {
+			end, _ = strconv.Atoi(args[i+1])
+		}
+		if start > end {
+			start, end = end, start
+		}
+		from := min(max(start+p.History, 0), len(p.Lines)-1)
+		to := min(max(end+p.History, 0), len(p.Lines)-1)
+		fmt.Println(strings.Join(p.Lines[from:to+1], "\n"))
+	default:
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func TestCaptureNewOutput(t *testing.T) {
+	tests := []struct {
+		name        string
+		before, now fakePane
+		want        string
+		wantStyled  []string // checked when set
+	}{
+		{
+			name:   "new output below the tail",
+			before: fakePane{Lines: []string{"l1", "l2", "$ ", "", ""}, CursorY: 2},
+			now:    fakePane{Lines: []string{"l1", "l2", "$ make", "o1", "$ "}, CursorY: 4},
+			want:   "$ make\no1",
+		},
+		{
+			name:   "nothing new",
+			before: fakePane{Lines: []string{"l1", "l2", "$ "}, CursorY: 2},
+			now:    fakePane{Lines: []string{"l1", "l2", "$ "}, CursorY: 2},
+			want:   "",
+		},
+		{
+			name:   "output scrolled into history",
+			before: fakePane{Lines: []string{"l1", "l2", "$ "}, CursorY: 2},
+			now:    fakePane{Lines: []string{"l1", "l2", "$ make", "o1", "o2", "$ "}, History: 3, CursorY: 2},
+			want:   "$ make\no1\no2",
+		},
+		{
+			name:   "history trimmed above the tail",
+			before: fakePane{Lines: []string{"h1", "h2", "l1", "l2", "$ "}, History: 2, CursorY: 2},
+			now:    fakePane{Lines: []string{"l1", "l2", "$ make", "o1", "$ "}, History: 2, CursorY: 2},
+			want:   "$ make\no1",
+		},
+		{
+			name:   "pane cleared",
+			before: fakePane{Lines: []string{"a", "b", "$ "}, CursorY: 2},
+			now:    fakePane{Lines: []string{"x", "y", "$ "}, CursorY: 2},
+			want:   "x\ny",
+		},
+		{
+			name:   "one line scrolled off the top",
+			before: fakePane{Lines: []string{"a", "b", "c", "$ "}, CursorY: 3},
+			now:    fakePane{Lines: []string{"a", "b", "c", "d", "$ "}, CursorY: 3, History: 1},
+			want:   "d",
+		},
+		{
+			name:   "blank lines before the cursor are dropped",
+			before: fakePane{Lines: []string{"a", "$ "}, CursorY: 1},
+			now:    fakePane{Lines: []string{"a", "out", "", "", "$ "}, CursorY: 4},
+			want:   "out",
+		},
+		{
+			name:       "styles carry across new lines",
+			before:     fakePane{Lines: []string{"a", "$ "}, CursorY: 1},
+			now:        fakePane{Lines: []string{"a", "\x1b[31merr", "more\x1b[39m", "plain", "$ "}, CursorY: 4},
+			want:       "err\nmore\nplain",
+			wantStyled: []string{"\x1b[31merr\x1b[0m", "\x1b[31mmore\x1b[39m\x1b[0m", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakePane(t, tt.before)
+			tail := readTail("%0")
+			if tail == nil {
+				t.Fatal("readTail failed")
+			}
+
+			useFakePane(t, tt.now)
+			msg := captureNewOutput("%0", tail)
+			if msg.Failed {
+				t.Fatalf("capture failed: %s", msg.Content)
+			}
+			if msg.Content != tt.want {
+				t.Errorf("content = %q, want %q", msg.Content, tt.want)
+			}
+			if tt.wantStyled != nil && !slices.Equal(msg.Styled, tt.wantStyled) {
+				t.Errorf("styled = %q, want %q", msg.Styled, tt.wantStyled)
+			}
+			if want := tt.now.History + tt.now.CursorY - 1; msg.Tail == nil || msg.Tail.Abs != want {
+				t.Errorf("new tail = %+v, want it to end at line %d", msg.Tail, want)
+			}
+		})
+	}
+}
+
+func TestIncrementalMsg(t *testing.T) {
+	msg := incrementalMsg([]string{"a", "", "b", " ", ""}, []string{"", "", "B", "", ""}, nil)
+	if msg.Content != "a\n\nb" || !slices.Equal(msg.Styled, []string{"", "", "B"}) || !msg.Incremental {
+		t.Errorf("incrementalMsg = %+v", msg)
+	}
+}
@@ -3,53 +3,103 @@ package main
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"os"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-const ipcSocketPath = "/tmp/clipnote.sock"
-
-// IPC message types (controller -> TUI)
-type ipcRequest struct {
-	Type  string `json:"type"`
-	Lines []int  `json:"lines,omitempty"`
+// IPCMsg delivers an IPC request into the bubbletea event loop
+type IPCMsg struct {
+	Request ipcRequest
+	ReplyCh chan<- ipcResponse
+	state   *atomic.Int32 // ipcPending until the loop starts it or forwardIPC times it out
 }
 
-// IPC response types (TUI -> controller)
-type ipcResponse struct {
-	Type    string      `json:"type"`
-	Data    any `json:"data,omitempty"`
-	Message string      `json:"message,omitempty"`
+// IPCMsg states: a request either runs or is answered with a timeout, never both
+const (
+	ipcPending int32 = iota
+	ipcStarted
+	ipcAbandoned
+)
+
+// start claims the request for the event loop; it reports false when the
+// client was already told it timed out, so it must not run
+func (msg IPCMsg) start() bool {
+	return msg.state == nil || msg.state.CompareAndSwap(ipcPending, ipcStarted)
 }
 
-// IPCMsg delivers an IPC request into the bubbletea event loop
-type IPCMsg struct {
-	Request  ipcRequest
-	ReplyCh  chan<- ipcResponse
+// ipcServerErrMsg reports that the IPC server could not start
+type ipcServerErrMsg struct{ err error }
+
+const (
+	ipcReplyTimeout  = 5 * time.Second        // how long a request may wait for the bubbletea loop
+	ipcWriteTimeout  = 2 * time.Second        // how long a response may take to reach the client
+	ipcShutdownGrace = 500 * time.Millisecond // how long exit waits for in-flight replies
+	ipcShutdownPoll  = 10 * time.Millisecond
+)
+
+// ipcServer tracks the running listener so it can be shut down on exit
+var ipcServer struct {
+	sync.Mutex
+	ln     net.Listener
+	socket string
 }
 
-// startIPCServer creates a Unix socket server and returns a tea.Cmd
-// that listens for incoming connections. Each request is forwarded
-// to the bubbletea event loop via IPCMsg.
-func startIPCServer() tea.Cmd {
+var (
+	ipcDone     = make(chan struct{}) // closed when the TUI exits
+	ipcStopOnce sync.Once
+	ipcInflight atomic.Int32 // requests and subscriptions still being answered
+)
+
+// startIPCServer creates the Unix socket server for this instance (see
+// ipcSocketPath) and returns a tea.Cmd that listens for incoming connections.
+// Each request is forwarded to the bubbletea event loop via IPCMsg.
+func startIPCServer(paneID, watchedID, sessionID string) tea.Cmd {
 	return func() tea.Msg {
-		// clean up stale socket
-		os.Remove(ipcSocketPath)
+		path, err := ipcSocketPath(paneID, sessionID)
+		if err != nil {
+			return ipcServerErrMsg{err}
+		}
+
+		// never steal the socket from a live instance; only clean up a stale one
+		if socketAlive(path) {
+			return ipcServerErrMsg{fmt.Errorf("another clipnote instance is serving %s", path)}
+		}
+		os.Remove(path)
 
-		ln, err := net.Listen("unix", ipcSocketPath)
+		ln, err := net.Listen("unix", path)
 		if err != nil {
-			return nil
+			return ipcServerErrMsg{err}
+		}
+		os.Chmod(path, 0600)
+
+		info := ipcInstance{
+			Pane:      paneID,
+			PaneID:    watchedID,
+			SessionID: sessionID,
+			PID:       os.Getpid(),
+			Socket:    path,
+		}
+		if err := writeInstanceInfo(info); err != nil {
+			ln.Close()
+			return ipcServerErrMsg{err}
 		}
 
+		ipcServer.Lock()
+		ipcServer.ln, ipcServer.socket = ln, path
+		ipcServer.Unlock()
+
 		// accept connections in background
 		go func() {
-			defer ln.Close()
-			defer os.Remove(ipcSocketPath)
-
 			for {
 				conn, err := ln.Accept()
 				if err != nil {
@@ -63,173 +113,509 @@ func startIPCServer() tea.Cmd {
 	}
 }
 
+// stopIPCServer is called once the bubbletea loop has returned. It closes the
+// listener, removes the socket and its info file, and gives waiting requests
+// a moment to send their "tui-exiting" errors.
+func stopIPCServer() {
+	ipcStopOnce.Do(func() { close(ipcDone) })
+
+	ipcServer.Lock()
+	if ipcServer.ln != nil {
+		ipcServer.ln.Close()
+		os.Remove(ipcServer.socket)
+		os.Remove(ipcInfoPath(ipcServer.socket))
+		ipcServer.ln = nil
+	}
+	ipcServer.Unlock()
+
+	for deadline := time.Now().Add(ipcShutdownGrace); ipcInflight.Load() > 0 && time.Now().Before(deadline); {
+		time.Sleep(ipcShutdownPoll)
+	}
+}
+
 // ipcProgram is set by the TUI so the IPC handler can inject messages
 var ipcProgram *tea.Program
 
 func handleIPCConn(conn net.Conn) {
 	defer conn.Close()
 
-	scanner := bufio.NewScanner(conn)
+	scanner := newLineScanner(conn)
 	for scanner.Scan() {
 		var req ipcRequest
 		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
-			writeJSON(conn, ipcResponse{Type: "error", Message: "invalid JSON"})
+			writeResponse(conn, req, ipcError(errInvalidRequest, "invalid JSON"))
+			continue
+		}
+		if req.V > ipcProtocolVersion {
+			writeResponse(conn, req, ipcError(errUnsupportedVersion,
+				"request is for protocol v%d, this TUI (clipnote %s) speaks v%d; restart the annotation pane to upgrade it",
+				req.V, version, ipcProtocolVersion))
 			continue
 		}
 
-		replyCh := make(chan ipcResponse, 1)
+		// subscribe turns the connection into a one-way event stream
+		if req.Type == "subscribe" {
+			serveSubscription(conn, scanner, req)
+			return
+		}
+
+		ipcInflight.Add(1)
+		err := writeResponse(conn, req, forwardIPC(req))
+		ipcInflight.Add(-1)
+		if err != nil {
+			return
+		}
+	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		writeResponse(conn, ipcRequest{}, ipcError(errInvalidRequest, "request larger than %d MB", ipcMaxLine>>20))
+	}
+}
+
+// forwardIPC hands a request to the bubbletea loop and waits for its reply,
+// giving up when the loop does not answer in time or the TUI exits
+func forwardIPC(req ipcRequest) ipcResponse {
+	if ipcProgram == nil {
+		return ipcError(errNotReady, "TUI not ready")
+	}
+	select {
+	case <-ipcDone:
+		return ipcError(errExiting, "TUI is exiting")
+	default:
+	}
+
+	// buffered, so a late reply from the loop never blocks it
+	replyCh := make(chan ipcResponse, 1)
+	state := new(atomic.Int32)
+	go ipcProgram.Send(IPCMsg{Request: req, ReplyCh: replyCh, state: state})
+
+	timer := time.NewTimer(ipcReplyTimeout)
+	defer timer.Stop()
+	select {
+	case resp := <-replyCh:
+		return resp
+	case <-ipcDone:
+		return ipcError(errExiting, "TUI is exiting")
+	case <-timer.C:
+		if state.CompareAndSwap(ipcPending, ipcAbandoned) {
+			return ipcError(errTimeout, "TUI did not answer within %s", ipcReplyTimeout)
+		}
+	}
+	// the loop started the request just in time; it has side effects, so
+	// wait for its outcome rather than report a timeout
+	select {
+	case resp := <-replyCh:
+		return resp
+	case <-ipcDone:
+		return ipcError(errExiting, "TUI is exiting")
+	}
+}
+
+// writeResponse stamps resp with the protocol version and the request ID, then sends it
+func writeResponse(conn net.Conn, req ipcRequest, resp ipcResponse) error {
+	resp.V = ipcProtocolVersion
+	resp.ID = req.ID
+	return writeJSON(conn, resp)
+}
 
-		if ipcProgram != nil {
-			ipcProgram.Send(IPCMsg{Request: req, ReplyCh: replyCh})
-		} else {
-			replyCh <- ipcResponse{Type: "error", Message: "TUI not ready"}
+// serveSubscription acknowledges a subscribe request, then streams events
+// to conn until the client disconnects
+func serveSubscription(conn net.Conn, scanner *bufio.Scanner, req ipcRequest) {
+	events := req.Events
+	for _, ev := range events {
+		if !slices.Contains(eventNames, ev) {
+			writeResponse(conn, req, ipcError(errInvalidArgument, "unknown event: %s (available: %s)",
+				ev, strings.Join(eventNames, ", ")))
+			return
 		}
+	}
+	if len(events) == 0 {
+		events = eventNames
+	}
 
-		// wait for reply from the bubbletea loop
-		resp := <-replyCh
-		writeJSON(conn, resp)
+	ipcInflight.Add(1)
+	defer ipcInflight.Add(-1)
+	sub := subscribe(events)
+	defer unsubscribe(sub)
+	if err := writeResponse(conn, req, ipcResult(subscribeResult{Subscribed: events})); err != nil {
+		return
+	}
+
+	// further input is ignored; EOF means the client went away
+	closed := make(chan struct{})
+	go func() {
+		for scanner.Scan() {
+		}
+		close(closed)
+	}()
+
+	for {
+		select {
+		case ev := <-sub.ch:
+			if err := writeJSON(conn, ev); err != nil {
+				return
+			}
+		case <-sub.overflow:
+			// deliver what was queued before the drop, then report the gap
+			for len(sub.ch) > 0 {
+				if err := writeJSON(conn, <-sub.ch); err != nil {
+					return
+				}
+			}
+			if n := sub.takeMissed(); n > 0 {
+				ev := ipcEvent{V: ipcProtocolVersion, Type: "event", Event: eventOverflow, Data: overflowEvent{Missed: n}}
+				if err := writeJSON(conn, ev); err != nil {
+					return
+				}
+			}
+		case <-closed:
+			return
+		case <-ipcDone:
+			writeResponse(conn, req, ipcError(errExiting, "TUI is exiting"))
+			return
+		}
 	}
 }
 
-func writeJSON(conn net.Conn, v any) {
+// writeJSON sends one NDJSON line; a client that stops reading is dropped
+// after ipcWriteTimeout
+func writeJSON(conn net.Conn, v any) error {
 	data, _ := json.Marshal(v)
 	data = append(data, '\n')
-	conn.Write(data)
+	conn.SetWriteDeadline(time.Now().Add(ipcWriteTimeout))
+	_, err := conn.Write(data)
+	return err
 }
 
 // handleIPC processes an IPC request within the model and returns a response.
 func (m *Model) handleIPC(req ipcRequest) ipcResponse {
 	switch req.Type {
+	case "hello":
+		return m.ipcHello()
 	case "capture":
-		return m.ipcCapture()
+		return m.ipcCapture(req)
 	case "mark":
 		return m.ipcMark(req.Lines)
+	case "unmark":
+		return m.ipcUnmark(req.Lines)
+	case "note":
+		return m.ipcNote(req.Lines, req.Note)
+	case "clear-marks":
+		return m.ipcClearMarks()
+	case "clear":
+		return m.ipcClear()
+	case "get-lines":
+		return m.ipcGetLines(req)
+	case "get-captures":
+		return m.ipcGetCaptures()
+	case "mark-pattern":
+		return m.ipcMarkPattern(req.Pattern, req.Note)
 	case "get-marks":
 		return m.ipcGetMarks()
 	case "export":
-		return m.ipcExport()
+		return m.ipcExport(req.Format)
+	case "batch":
+		return m.ipcBatch(req.Requests)
 	default:
-		return ipcResponse{Type: "error", Message: fmt.Sprintf("unknown command: %s", req.Type)}
+		return ipcError(errUnknownCommand, "unknown command: %s", req.Type)
 	}
 }
 
-func (m *Model) ipcCapture() ipcResponse {
-	out, err := execCommand("tmux", "capture-pane", "-p", "-t", m.tmuxPane).CombinedOutput()
-	if err != nil {
-		return ipcResponse{Type: "error", Message: "capture failed: " + strings.TrimSpace(string(out))}
+func (m *Model) ipcHello() ipcResponse {
+	return ipcResult(helloResult{
+		Version:   version,
+		Protocol:  ipcProtocolVersion,
+		Commands:  ipcCommands,
+		Events:    eventNames,
+		Formats:   exporterNames(),
+		Pane:      m.tmuxPane,
+		SessionID: m.sessionID,
+		PID:       os.Getpid(),
+	})
+}
+
+// ipcBatch runs requests one after another within a single Update, so no
+// keypress or other client can interleave. The first error stops the batch;
+// later requests are reported as skipped. Earlier changes are not rolled back.
+func (m *Model) ipcBatch(reqs []ipcRequest) ipcResponse {
+	if len(reqs) == 0 {
+		return ipcError(errInvalidArgument, "empty batch")
+	}
+
+	responses := make([]ipcResponse, len(reqs))
+	failed := false
+	for i, req := range reqs {
+		var resp ipcResponse
+		switch {
+		case failed:
+			resp = ipcError(errSkipped, "skipped after an earlier error")
+		case req.V > ipcProtocolVersion:
+			resp = ipcError(errUnsupportedVersion, "request is for protocol v%d, this TUI speaks v%d", req.V, ipcProtocolVersion)
+		case req.Type == "batch" || req.Type == "subscribe":
+			resp = ipcError(errInvalidArgument, "%s cannot be used inside a batch", req.Type)
+		default:
+			resp = m.handleIPC(req)
+		}
+		failed = failed || resp.Type == "error"
+		resp.V, resp.ID = ipcProtocolVersion, req.ID
+		responses[i] = resp
+	}
+	return ipcResult(batchResult{Responses: responses})
+}
+
+// ipcCapture captures the watched pane the same way the capture keys do
+func (m *Model) ipcCapture(req ipcRequest) ipcResponse {
+	spec := captureSpec{Mode: req.Mode, Start: req.Start, End: req.End}
+	if spec.Mode == "" {
+		// infer the mode from the arguments given
+		switch {
+		case req.Start != nil || req.End != nil:
+			spec.Mode = captureModeRange
+		case len(req.Lines) > 0:
+			spec.Mode = captureModeLines
+		default:
+			spec.Mode = captureModeVisible
+		}
 	}
 
-	content := strings.TrimRight(string(out), "\n")
-	newLines := strings.Split(content, "\n")
+	switch spec.Mode {
+	case captureModeLines:
+		if len(req.Lines) != 1 || req.Lines[0] <= 0 {
+			return ipcError(errInvalidArgument, "lines mode needs a positive line count")
+		}
+		spec.Lines = req.Lines[0]
+	case captureModeRange:
+		if spec.Start == nil && spec.End == nil {
+			return ipcError(errInvalidArgument, "range mode needs start and/or end")
+		}
+		if spec.Start != nil && spec.End != nil && *spec.Start > *spec.End {
+			return ipcError(errInvalidArgument, "start %d is after end %d", *spec.Start, *spec.End)
+		}
+	case captureModeVisible, captureModeAll, captureModeNew:
+	default:
+		return ipcError(errInvalidArgument, "unknown capture mode: %s (available: %s)",
+			spec.Mode, strings.Join(captureModes, ", "))
+	}
 
-	m.captureCount++
-	if len(m.lines) > 0 {
-		separator := fmt.Sprintf("─── Capture #%d ───", m.captureCount)
-		m.lines = append(m.lines, separator)
+	msg := capturePane(m.sourcePane(), spec, m.sourceTail())
+	msg.IPC = true
+	if msg.Failed {
+		return ipcError(errFailed, "%s", strings.ReplaceAll(strings.TrimPrefix(msg.Content, "Capture failed:\n"), "\n", " "))
 	}
-	jumpTo := len(m.lines)
-	m.lines = append(m.lines, newLines...)
-	m.cursorLine = jumpTo
-	m.syncViewport()
-	m.statusMsg = fmt.Sprintf("Captured %s lines (total %s)", itoa(len(newLines)), itoa(len(m.lines)))
 
-	return ipcResponse{
-		Type: "result",
-		Data: map[string]any{
-			"lines_captured": len(newLines),
-			"total_lines":    len(m.lines),
-		},
+	before := m.captureCount
+	*m = m.handleCaptureAppend(msg)
+	res := captureResult{Mode: spec.Mode, Pane: msg.Pane, TotalLines: len(m.lines)}
+	if m.captureCount != before {
+		res.Capture = m.captureCount
+		res.LinesCaptured = len(strings.Split(msg.Content, "\n"))
 	}
+	return ipcResult(res)
 }
 
 func (m *Model) ipcMark(lines []int) ipcResponse {
 	if len(lines) == 0 {
-		return ipcResponse{Type: "error", Message: "no lines specified"}
+		return ipcError(errInvalidArgument, "no lines specified")
 	}
 
-	marked := 0
+	marked := []int{}
+	skipped := []skippedLine{}
+	var added []Mark
 	for _, line := range lines {
-		if line < 0 || line >= len(m.lines) {
-			continue
+		switch {
+		case line < 0 || line >= len(m.lines):
+			skipped = append(skipped, skippedLine{line, skipOutOfRange})
+		case m.isHeader(line):
+			skipped = append(skipped, skippedLine{line, skipHeader})
+		case m.HasMark(line):
+			skipped = append(skipped, skippedLine{line, skipAlreadyMarked})
+		default:
+			added = append(added, m.appendMark(line, ""))
+			marked = append(marked, line)
 		}
-		if !m.HasMark(line) {
-			m.ToggleMark(line)
-			marked++
+	}
+	if len(added) > 0 {
+		m.saveSession()
+	}
+	publishMarks(eventMarkAdded, added)
+	m.statusMsg = fmt.Sprintf("Marked %d lines via IPC", len(marked))
+
+	return ipcResult(markResult{Marked: marked, Skipped: skipped})
+}
+
+// ipcUnmark removes the marks covering the given lines (a whole range mark
+// goes when any of its lines is given)
+func (m *Model) ipcUnmark(lines []int) ipcResponse {
+	if len(lines) == 0 {
+		return ipcError(errInvalidArgument, "no lines specified")
+	}
+
+	unmarked := []int{}
+	skipped := []skippedLine{}
+	var removed []Mark
+	for _, line := range lines {
+		switch {
+		case line < 0 || line >= len(m.lines):
+			skipped = append(skipped, skippedLine{line, skipOutOfRange})
+		case !m.HasMark(line):
+			skipped = append(skipped, skippedLine{line, skipNotMarked})
+		default:
+			mk, _ := m.dropMark(line)
+			removed = append(removed, mk)
+			unmarked = append(unmarked, line)
 		}
 	}
-	m.statusMsg = fmt.Sprintf("Marked %d lines via IPC", marked)
+	if len(removed) > 0 {
+		m.saveSession()
+	}
+	publishMarks(eventMarkRemoved, removed)
+	m.statusMsg = fmt.Sprintf("Unmarked %d lines via IPC", len(unmarked))
+
+	return ipcResult(unmarkResult{Unmarked: unmarked, Skipped: skipped})
+}
 
-	return ipcResponse{
-		Type: "result",
-		Data: map[string]any{
-			"marked": marked,
-		},
+// ipcNote sets the note on the mark covering a line, marking the line first
+// if needed. An empty note clears it.
+func (m *Model) ipcNote(lines []int, note string) ipcResponse {
+	if len(lines) != 1 {
+		return ipcError(errInvalidArgument, "note takes exactly one line")
 	}
+	line := lines[0]
+	if line < 0 || line >= len(m.lines) {
+		return ipcError(errInvalidArgument, "line %d %s", line, skipOutOfRange)
+	}
+	if m.isHeader(line) {
+		return ipcError(errInvalidArgument, "line %d is a %s", line, skipHeader)
+	}
+
+	created := !m.HasMark(line)
+	m.AddMarkWithNote(line, note)
+	mk := m.GetMark(line)
+	m.statusMsg = "Note set on " + mk.Label() + " via IPC"
+
+	return ipcResult(noteResult{Mark: newMarkData(*mk), Created: created})
 }
 
-type markData struct {
-	Line int    `json:"line"`
-	Text string `json:"text"`
-	Note string `json:"note,omitempty"`
+func (m *Model) ipcClearMarks() ipcResponse {
+	removed := m.ClearMarks()
+	m.statusMsg = fmt.Sprintf("Cleared %d marks via IPC", removed)
+	return ipcResult(clearMarksResult{Removed: removed})
 }
 
-func (m *Model) ipcGetMarks() ipcResponse {
-	marks := make([]markData, len(m.marks))
-	for i, mk := range m.marks {
-		marks[i] = markData{Line: mk.Line, Text: mk.Text, Note: mk.Note}
+func (m *Model) ipcClear() ipcResponse {
+	lines, marks := len(m.lines), len(m.marks)
+	m.clearAll()
+	m.statusMsg = "Cleared all content and marks via IPC"
+	return ipcResult(clearResult{LinesRemoved: lines, MarksRemoved: marks})
+}
+
+func (m *Model) ipcMarkPattern(pattern, note string) ipcResponse {
+	if pattern == "" {
+		return ipcError(errInvalidArgument, "no pattern specified")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return ipcError(errInvalidArgument, "invalid pattern: %v", err)
 	}
-	return ipcResponse{Type: "result", Data: marks}
+
+	matches := m.matchingLines(re)
+	marked, noted := m.MarkLines(matches, note)
+	m.statusMsg = fmt.Sprintf("Marked %d of %d lines matching /%s/ via IPC", marked, len(matches), pattern)
+
+	return ipcResult(markPatternResult{Marked: marked, Noted: noted, Matches: len(matches)})
 }
 
-func (m *Model) ipcExport() ipcResponse {
-	text := m.ExportMarks()
-	if text == "" {
-		return ipcResponse{Type: "result", Data: map[string]string{"exported": ""}}
+// ipcGetLines returns captured lines with their indices for use with mark,
+// filtered by start/end, capture number and pattern. Block headers are left out.
+func (m *Model) ipcGetLines(req ipcRequest) ipcResponse {
+	start, end := 0, len(m.lines)-1
+	if req.Start != nil {
+		start = max(start, *req.Start)
+	}
+	if req.End != nil {
+		end = min(end, *req.End)
+	}
+	if req.Capture != 0 {
+		if req.Capture < 0 || req.Capture > len(m.captures) {
+			return ipcError(errInvalidArgument, "no capture #%d (have %d)", req.Capture, len(m.captures))
+		}
+		first, last := m.blockSpan(req.Capture - 1)
+		start, end = max(start, first), min(end, last)
+	}
+	var re *regexp.Regexp
+	if req.Pattern != "" {
+		var err error
+		if re, err = regexp.Compile(req.Pattern); err != nil {
+			return ipcError(errInvalidArgument, "invalid pattern: %v", err)
+		}
+	}
+	limit := req.Limit
+	if limit < 0 {
+		return ipcError(errInvalidArgument, "limit must be positive")
+	}
+	if limit == 0 {
+		limit = ipcLineLimit
 	}
 
-	msg := m.CopyMarksToClipboard()
-	return ipcResponse{
-		Type: "result",
-		Data: map[string]any{
-			"exported": text,
-			"status":   msg,
-		},
+	result := linesResult{Lines: []lineData{}, TotalLines: len(m.lines)}
+	for i := start; i <= end; i++ {
+		text := m.lines[i]
+		if m.isHeader(i) || (re != nil && !re.MatchString(text)) {
+			continue
+		}
+		if len(result.Lines) == limit {
+			result.Next = &i
+			break
+		}
+		result.Lines = append(result.Lines, lineData{Line: i, Text: text, Capture: m.captureOf(i), Marked: m.HasMark(i)})
 	}
+	return ipcResult(result)
 }
 
-// sendIPCCommand connects to the IPC socket, sends a command, and prints the response.
-// Used by the CLI client (clipnote ipc <command>).
-func sendIPCCommand(command string, args []string) error {
-	conn, err := net.Dial("unix", ipcSocketPath)
-	if err != nil {
-		return fmt.Errorf("cannot connect to clipnote (is annotation TUI running?): %w", err)
+// ipcGetCaptures describes every capture block and where it came from
+func (m *Model) ipcGetCaptures() ipcResponse {
+	captures := make([]captureData, len(m.captures))
+	for i, b := range m.captures {
+		first, last := m.blockSpan(i)
+		captures[i] = captureData{
+			Capture: i + 1,
+			Pane:    b.Pane,
+			Label:   b.Label,
+			Time:    b.Time,
+			Mode:    b.Mode,
+			IPC:     b.IPC,
+			First:   first,
+			Last:    last,
+		}
 	}
-	defer conn.Close()
+	return ipcResult(captures)
+}
 
-	req := ipcRequest{Type: command}
+func (m *Model) ipcGetMarks() ipcResponse {
+	marks := make([]markData, len(m.marks))
+	for i, mk := range m.marks {
+		marks[i] = newMarkData(mk)
+	}
+	return ipcResult(marks)
+}
 
-	// parse additional args for mark command
-	if command == "mark" && len(args) > 0 {
-		var lines []int
-		for _, arg := range args {
-			var n int
-			if _, err := fmt.Sscanf(arg, "%d", &n); err == nil {
-				lines = append(lines, n)
-			}
+func (m *Model) ipcExport(format string) ipcResponse {
+	e := m.exporter()
+	if format != "" {
+		var ok bool
+		if e, ok = findExporter(format); !ok {
+			return ipcError(errInvalidArgument, "unknown format: %s (available: %s)",
+				format, strings.Join(exporterNames(), ", "))
 		}
-		req.Lines = lines
 	}
 
-	data, _ := json.Marshal(req)
-	data = append(data, '\n')
-	if _, err := conn.Write(data); err != nil {
-		return fmt.Errorf("failed to send command: %w", err)
+	text, err := m.ExportMarksAs(e)
+	if err != nil {
+		return ipcError(errFailed, "export failed: %v", err)
 	}
-
-	scanner := bufio.NewScanner(conn)
-	if scanner.Scan() {
-		fmt.Println(scanner.Text())
+	if text == "" {
+		return ipcResult(exportResult{})
 	}
 
-	return nil
+	msg := m.copyExportToClipboard(e.Name(), text)
+	return ipcResult(exportResult{Exported: text, Format: e.Name(), Status: msg})
 }
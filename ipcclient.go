@@ -0,0 +1,599 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ipcDialTimeout = 2 * time.Second
+	// ipcClientTimeout bounds each round trip; it exceeds ipcReplyTimeout so a
+	// stuck TUI normally answers with a "timeout" error first
+	ipcClientTimeout = ipcReplyTimeout + 5*time.Second
+)
+
+// ipcClient is a connection to a running annotation TUI that has completed
+// the hello handshake
+type ipcClient struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+	server  helloResult // what the TUI reported about itself
+	nextID  int
+}
+
+// dialIPC connects to the socket and checks that the TUI speaks our protocol
+func dialIPC(socket string) (*ipcClient, error) {
+	conn, err := net.DialTimeout("unix", socket, ipcDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to clipnote (is annotation TUI running?): %w", err)
+	}
+	c := &ipcClient{conn: conn, scanner: newLineScanner(conn)}
+	if err := c.handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *ipcClient) Close() error {
+	return c.conn.Close()
+}
+
+// handshake sends hello and records the server's version and commands
+func (c *ipcClient) handshake() error {
+	line, err := c.roundTrip(ipcRequest{Type: "hello"})
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		ipcResponse
+		Data *helloResult `json:"data"`
+	}
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return fmt.Errorf("invalid hello reply: %w", err)
+	}
+
+	if resp.Type == "error" {
+		// TUIs from before the versioned protocol reject hello unversioned
+		if resp.V == 0 {
+			c.server = helloResult{Version: "unversioned", Commands: legacyIPCCommands}
+			return nil
+		}
+		return fmt.Errorf("handshake failed: %s", resp.Message)
+	}
+	if resp.Data == nil {
+		return fmt.Errorf("invalid hello reply: no data")
+	}
+	c.server = *resp.Data
+
+	if c.server.Protocol != ipcProtocolVersion {
+		if c.server.Protocol < ipcProtocolVersion {
+			return fmt.Errorf("the running TUI (clipnote %s) speaks IPC protocol v%d but this client (clipnote %s) needs v%d; restart the annotation pane",
+				c.server.Version, c.server.Protocol, version, ipcProtocolVersion)
+		}
+		return fmt.Errorf("the running TUI (clipnote %s) speaks IPC protocol v%d but this client (clipnote %s) only knows v%d; update the clipnote binary",
+			c.server.Version, c.server.Protocol, version, ipcProtocolVersion)
+	}
+	return nil
+}
+
+// checkCommand reports a command the running TUI does not know
+func (c *ipcClient) checkCommand(command string) error {
+	if slices.Contains(c.server.Commands, command) {
+		return nil
+	}
+	if !slices.Contains(ipcCommands, command) {
+		return fmt.Errorf("unknown command: %s (available: %s)", command, strings.Join(c.server.Commands, ", "))
+	}
+	return fmt.Errorf("the running TUI (clipnote %s) does not support %s; restart the annotation pane to upgrade it",
+		c.server.Version, command)
+}
+
+// roundTrip sends a request stamped with the protocol version and (unless it
+// has one) a fresh ID, and returns the raw response line
+func (c *ipcClient) roundTrip(req ipcRequest) ([]byte, error) {
+	if err := c.send(req); err != nil {
+		return nil, err
+	}
+	return c.readLine()
+}
+
+func (c *ipcClient) send(req ipcRequest) error {
+	c.conn.SetDeadline(time.Now().Add(ipcClientTimeout))
+	if req.V == 0 {
+		req.V = ipcProtocolVersion
+	}
+	if req.ID == nil {
+		c.nextID++
+		req.ID = json.RawMessage(strconv.Itoa(c.nextID))
+	}
+
+	data, _ := json.Marshal(req)
+	data = append(data, '\n')
+	if _, err := c.conn.Write(data); err != nil {
+		return fmt.Errorf("failed to send command: %w", err)
+	}
+	return nil
+}
+
+func (c *ipcClient) readLine() ([]byte, error) {
+	if !c.scanner.Scan() {
+		err := c.scanner.Err()
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, fmt.Errorf("no response from clipnote within %s (TUI busy or stuck?)", ipcClientTimeout)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		return nil, fmt.Errorf("connection closed by clipnote")
+	}
+	return c.scanner.Bytes(), nil
+}
+
+// parseLineArgs parses 0-indexed line numbers given on the command line
+func parseLineArgs(args []string) ([]int, error) {
+	lines := make([]int, 0, len(args))
+	for _, arg := range args {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid line number: %s", arg)
+		}
+		lines = append(lines, n)
+	}
+	return lines, nil
+}
+
+// parseRequestFlags fills request fields from command line flags; flags lists
+// the ones the command accepts
+func parseRequestFlags(req *ipcRequest, args []string, flags ...string) error {
+	for len(args) > 0 {
+		if len(args) < 2 {
+			return fmt.Errorf("%s needs a value", args[0])
+		}
+		flag, val := args[0], args[1]
+		args = args[2:]
+		if !slices.Contains(flags, flag) {
+			return fmt.Errorf("unknown %s flag: %s", req.Type, flag)
+		}
+
+		switch flag {
+		case "--pattern":
+			req.Pattern = val
+			continue
+		case "--mode":
+			req.Mode = val
+			continue
+		}
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("%s: invalid number: %s", flag, val)
+		}
+		switch flag {
+		case "--start":
+			req.Start = &n
+		case "--end":
+			req.End = &n
+		case "--capture":
+			req.Capture = n
+		case "--lines":
+			req.Lines = []int{n}
+		case "--limit":
+			req.Limit = n
+		}
+	}
+	return nil
+}
+
+// buildIPCRequest turns `clipnote ipc <command> [args...]` into a request
+func buildIPCRequest(command string, args []string) (ipcRequest, error) {
+	req := ipcRequest{Type: command}
+
+	switch command {
+	case "mark", "unmark":
+		// <line> [line...]
+		lines, err := parseLineArgs(args)
+		if err != nil {
+			return req, err
+		}
+		req.Lines = lines
+
+	case "capture":
+		// [--mode M] [--lines N] [--start N] [--end N]
+		if err := parseRequestFlags(&req, args, "--mode", "--lines", "--start", "--end"); err != nil {
+			return req, err
+		}
+
+	case "get-lines":
+		// [--start N] [--end N] [--capture N] [--pattern RE] [--limit N]
+		if err := parseRequestFlags(&req, args, "--start", "--end", "--capture", "--pattern", "--limit"); err != nil {
+			return req, err
+		}
+
+	case "note":
+		// <line> <text...>
+		if len(args) == 0 {
+			return req, fmt.Errorf("usage: note <line> <text>")
+		}
+		lines, err := parseLineArgs(args[:1])
+		if err != nil {
+			return req, err
+		}
+		req.Lines = lines
+		req.Note = strings.Join(args[1:], " ")
+
+	case "mark-pattern":
+		// <regex> [note...]
+		if len(args) > 0 {
+			req.Pattern = args[0]
+			req.Note = strings.Join(args[1:], " ")
+		}
+
+	case "subscribe":
+		// [event...]
+		req.Events = args
+
+	case "export":
+		// optional format argument
+		if len(args) > 0 {
+			req.Format = args[0]
+		}
+	}
+	return req, nil
+}
+
+// ipcOptions are the flags of `clipnote ipc` given before the command
+type ipcOptions struct {
+	pane      string
+	sessionID string
+	format    string // "json" (default) or "text"
+	pretty    bool   // indent JSON output
+	atomic    bool   // with "-": send stdin as one batch
+}
+
+// parseIPCOptions splits `clipnote ipc` arguments into options and the command
+func parseIPCOptions(args []string) (ipcOptions, []string, error) {
+	opts := ipcOptions{format: "json"}
+	for len(args) > 0 {
+		switch args[0] {
+		case "--pretty":
+			opts.pretty = true
+			args = args[1:]
+		case "--atomic":
+			opts.atomic = true
+			args = args[1:]
+		case "--pane", "--session", "--format":
+			if len(args) < 2 {
+				return opts, nil, fmt.Errorf("%s needs a value", args[0])
+			}
+			switch args[0] {
+			case "--pane":
+				opts.pane = args[1]
+			case "--session":
+				opts.sessionID = args[1]
+			default:
+				if args[1] != "json" && args[1] != "text" {
+					return opts, nil, fmt.Errorf("unknown output format: %s (json or text)", args[1])
+				}
+				opts.format = args[1]
+			}
+			args = args[2:]
+		default:
+			return opts, args, nil
+		}
+	}
+	return opts, nil, nil
+}
+
+// runIPCClient sends one command to the instance selected by --pane/--session
+// (see resolveSocket), or a stream of requests from stdin for "-";
+// "list" prints the running instances instead
+func runIPCClient(args []string) error {
+	opts, rest, err := parseIPCOptions(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return fmt.Errorf("missing command (see clipnote --help)")
+	}
+	if opts.atomic && rest[0] != "-" {
+		return fmt.Errorf("--atomic only applies to requests read from stdin (ipc -)")
+	}
+
+	if rest[0] == "list" {
+		instances, err := listInstances()
+		if err != nil {
+			return err
+		}
+		if instances == nil {
+			instances = []ipcInstance{}
+		}
+		data, _ := json.Marshal(ipcResponse{V: ipcProtocolVersion, Type: "result", Data: instances})
+		opts.print(data)
+		return nil
+	}
+
+	socket, err := resolveSocket(opts.pane, opts.sessionID)
+	if err != nil {
+		return err
+	}
+	if rest[0] == "-" {
+		return runIPCStream(socket, os.Stdin, opts)
+	}
+	return sendIPCCommand(socket, rest[0], rest[1:], opts)
+}
+
+// sendIPCCommand connects to the IPC socket, sends a command, and prints the response.
+// Used by the CLI client (clipnote ipc <command>).
+func sendIPCCommand(socket, command string, args []string, opts ipcOptions) error {
+	req, err := buildIPCRequest(command, args)
+	if err != nil {
+		return err
+	}
+
+	c, err := dialIPC(socket)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := c.checkCommand(command); err != nil {
+		return err
+	}
+	if command == "hello" {
+		// the handshake already asked; print what the TUI said
+		data, _ := json.Marshal(ipcResponse{V: ipcProtocolVersion, Type: "result", Data: c.server})
+		opts.print(data)
+		return nil
+	}
+
+	if err := c.send(req); err != nil {
+		return err
+	}
+
+	if command == "subscribe" {
+		// print events as they arrive until the TUI goes away
+		c.conn.SetReadDeadline(time.Time{})
+		for c.scanner.Scan() {
+			opts.print(c.scanner.Bytes())
+		}
+		return nil
+	}
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	opts.print(line)
+	return nil
+}
+
+// runIPCStream sends NDJSON requests read from r over one connection and
+// prints each response as it arrives. With --atomic all requests are sent as
+// one batch that the TUI runs without interleaving anything else. Returns an
+// error if any request failed.
+func runIPCStream(socket string, r io.Reader, opts ipcOptions) error {
+	c, err := dialIPC(socket)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	var batch []ipcRequest
+	total, failed := 0, 0
+	report := func(line []byte) {
+		opts.print(line)
+		if isErrorResponse(line) {
+			failed++
+		}
+	}
+
+	scanner := newLineScanner(r)
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		total++
+
+		var req ipcRequest
+		if err := json.Unmarshal([]byte(text), &req); err != nil {
+			if opts.atomic {
+				return fmt.Errorf("request %d: invalid JSON: %v", total, err)
+			}
+			report(localError(req, errInvalidRequest, "invalid JSON: %v", err))
+			continue
+		}
+		if req.Type == "subscribe" {
+			return fmt.Errorf("request %d: subscribe cannot be combined with other requests", total)
+		}
+		if err := c.checkCommand(req.Type); err != nil {
+			if opts.atomic {
+				return fmt.Errorf("request %d: %v", total, err)
+			}
+			report(localError(req, errUnknownCommand, "%v", err))
+			continue
+		}
+
+		if opts.atomic {
+			// number the requests like roundTrip would, so responses can be matched
+			if req.ID == nil {
+				c.nextID++
+				req.ID = json.RawMessage(strconv.Itoa(c.nextID))
+			}
+			batch = append(batch, req)
+			continue
+		}
+		line, err := c.roundTrip(req)
+		if err != nil {
+			return err
+		}
+		report(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read requests: %w", err)
+	}
+
+	if opts.atomic && len(batch) > 0 {
+		if err := c.checkCommand("batch"); err != nil {
+			return err
+		}
+		line, err := c.roundTrip(ipcRequest{Type: "batch", Requests: batch})
+		if err != nil {
+			return err
+		}
+		var resp struct {
+			ipcResponse
+			Data *struct {
+				Responses []json.RawMessage `json:"responses"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(line, &resp); err != nil || resp.Data == nil {
+			// the batch itself failed; show the TUI's answer
+			report(line)
+			return fmt.Errorf("batch failed")
+		}
+		for _, sub := range resp.Data.Responses {
+			report(sub)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d requests failed", failed, total)
+	}
+	return nil
+}
+
+// localError builds an error response for a request the client did not send
+func localError(req ipcRequest, code, format string, args ...any) []byte {
+	resp := ipcError(code, format, args...)
+	resp.V, resp.ID = ipcProtocolVersion, req.ID
+	data, _ := json.Marshal(resp)
+	return data
+}
+
+func isErrorResponse(line []byte) bool {
+	var resp struct {
+		Type string `json:"type"`
+	}
+	return json.Unmarshal(line, &resp) == nil && resp.Type == "error"
+}
+
+// print writes one response line in the selected output format
+func (o ipcOptions) print(line []byte) {
+	switch {
+	case o.format == "text":
+		fmt.Println(formatIPCText(line))
+	case o.pretty:
+		var buf bytes.Buffer
+		if json.Indent(&buf, line, "", "  ") == nil {
+			fmt.Println(buf.String())
+			return
+		}
+		fmt.Println(string(line))
+	default:
+		fmt.Println(string(line))
+	}
+}
+
+// formatIPCText renders a response or event for humans: errors as one line,
+// results as "key: value" lines with lists as "- item" entries
+func formatIPCText(line []byte) string {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var resp map[string]any
+	if err := dec.Decode(&resp); err != nil {
+		return string(line)
+	}
+
+	switch resp["type"] {
+	case "error":
+		msg := "error: " + fmt.Sprint(resp["message"])
+		if code, ok := resp["code"].(string); ok {
+			msg += " (" + code + ")"
+		}
+		return msg
+	case "event":
+		text := fmt.Sprint(resp["event"])
+		if data, ok := resp["data"]; ok {
+			text += " " + inlineText(data)
+		}
+		return text
+	}
+	data, ok := resp["data"]
+	if !ok {
+		return "ok"
+	}
+	return strings.TrimRight(blockText(data, ""), "\n")
+}
+
+// blockText renders a JSON value over several lines
+func blockText(v any, indent string) string {
+	var sb strings.Builder
+	switch v := v.(type) {
+	case map[string]any:
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			switch val := v[k].(type) {
+			case map[string]any, []any:
+				sb.WriteString(indent + k + ":\n")
+				sb.WriteString(blockText(val, indent+"  "))
+			case string:
+				if strings.Contains(val, "\n") {
+					sb.WriteString(indent + k + ":\n")
+					sb.WriteString(indent + "  " + strings.ReplaceAll(val, "\n", "\n"+indent+"  ") + "\n")
+				} else {
+					sb.WriteString(indent + k + ": " + val + "\n")
+				}
+			default:
+				sb.WriteString(indent + k + ": " + inlineText(val) + "\n")
+			}
+		}
+	case []any:
+		if len(v) == 0 {
+			sb.WriteString(indent + "(none)\n")
+		}
+		for _, item := range v {
+			sb.WriteString(indent + "- " + inlineText(item) + "\n")
+		}
+	default:
+		sb.WriteString(indent + inlineText(v) + "\n")
+	}
+	return sb.String()
+}
+
+// inlineText renders a JSON value on one line; objects become key=value pairs
+func inlineText(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]any:
+		parts := make([]string, 0, len(v))
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			val := inlineText(v[k])
+			if s, ok := v[k].(string); ok && (s == "" || strings.ContainsAny(s, " \t\n\"=")) {
+				val = strconv.Quote(s)
+			}
+			parts = append(parts, k+"="+val)
+		}
+		return strings.Join(parts, " ")
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = inlineText(item)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
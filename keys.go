@@ -1,6 +1,12 @@
 package main
 
-import "github.com/charmbracelet/bubbles/key"
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
 
 type KeyMap struct {
 	Up           key.Binding
@@ -13,33 +19,252 @@ type KeyMap struct {
 	Quit         key.Binding
 	Help         key.Binding
 	SubmitNote   key.Binding
+	Confirm      key.Binding // enter — accept the search, line count or picked pane
 	Escape       key.Binding
 	ShrinkLeft   key.Binding
 	ExpandLeft   key.Binding
 	Capture      key.Binding // r — capture visible area (append)
 	CaptureRange key.Binding // R — custom range capture (append)
+	CaptureNew   key.Binding // a — capture only output since the previous capture
+	Follow       key.Binding // f — toggle follow mode (stream new output)
 	ClearAll     key.Binding // ctrl+r — clear all content and marks
 	PasteToPane  key.Binding // P — paste marks to left pane
 	ViewNote     key.Binding // v — view note in overlay
+	Visual       key.Binding // V — visual line selection for range marks
+	ExportFormat key.Binding // e — cycle export format
+	Search       key.Binding // / — search forward
+	SearchBack   key.Binding // \ — search backward
+	SearchNext   key.Binding // n — next match
+	SearchPrev   key.Binding // N — previous match
+	MarkMatches  key.Binding // M — mark every search match
+	NoteMatches  key.Binding // C — mark every search match with one note
+	PanePicker   key.Binding // p — pick the pane to capture from
+	NextBlock    key.Binding // } — jump to the next capture block
+	PrevBlock    key.Binding // { — jump to the previous capture block
+	Fold         key.Binding // z — fold/unfold the capture block under the cursor
+	FoldAll      key.Binding // Z — fold/unfold every capture block
+	DeleteBlock  key.Binding // D — delete the capture block under the cursor and its marks
+	Wrap         key.Binding // w — toggle soft wrap of long lines
+	ScrollLeft   key.Binding // h — scroll the content panel left
+	ScrollRight  key.Binding // l — scroll the content panel right
+}
+
+// keyDef ties a binding to its config name and help description
+type keyDef struct {
+	name    string // key in the config "keys" object
+	desc    string // help text shown in the overlay and --help
+	binding *key.Binding
+}
+
+// defs lists the bindings in help order
+func (k *KeyMap) defs() []keyDef {
+	return []keyDef{
+		{"capture", "capture visible area", &k.Capture},
+		{"capture_range", "custom range capture", &k.CaptureRange},
+		{"capture_new", "capture new output only", &k.CaptureNew},
+		{"follow", "toggle follow mode (live)", &k.Follow},
+		{"pane_picker", "pick the pane to capture from", &k.PanePicker},
+		{"clear_all", "clear all content and marks", &k.ClearAll},
+		{"delete_block", "delete capture under cursor (and its marks)", &k.DeleteBlock},
+		{"up", "move up", &k.Up},
+		{"down", "move down", &k.Down},
+		{"top", "jump to top", &k.Top},
+		{"bottom", "jump to bottom", &k.Bottom},
+		{"next_block", "next capture", &k.NextBlock},
+		{"prev_block", "previous capture", &k.PrevBlock},
+		{"fold", "fold/unfold capture", &k.Fold},
+		{"fold_all", "fold/unfold all captures", &k.FoldAll},
+		{"wrap", "toggle wrapping long lines", &k.Wrap},
+		{"scroll_left", "scroll left (no wrap)", &k.ScrollLeft},
+		{"scroll_right", "scroll right (no wrap)", &k.ScrollRight},
+		{"search", "search forward (regex)", &k.Search},
+		{"search_back", "search backward (regex)", &k.SearchBack},
+		{"search_next", "next match", &k.SearchNext},
+		{"search_prev", "previous match", &k.SearchPrev},
+		{"mark", "toggle mark", &k.Mark},
+		{"comment", "mark + note", &k.Comment},
+		{"mark_matches", "mark all search matches", &k.MarkMatches},
+		{"note_matches", "mark all search matches + note", &k.NoteMatches},
+		{"visual", "visual select (mark/note the range)", &k.Visual},
+		{"view_note", "view note", &k.ViewNote},
+		{"export", "export to clipboard", &k.Submit},
+		{"paste", "paste to left pane", &k.PasteToPane},
+		{"export_format", "cycle export format", &k.ExportFormat},
+		{"shrink_left", "shrink content panel", &k.ShrinkLeft},
+		{"expand_left", "expand content panel", &k.ExpandLeft},
+		{"help", "this help", &k.Help},
+		{"quit", "quit", &k.Quit},
+		{"submit_note", "submit note", &k.SubmitNote},
+		{"confirm", "confirm search, line count or pane", &k.Confirm},
+		{"escape", "cancel", &k.Escape},
+	}
+}
+
+func newKeyMap() KeyMap {
+	k := KeyMap{
+		Up:           key.NewBinding(key.WithKeys("k", "up")),
+		Down:         key.NewBinding(key.WithKeys("j", "down")),
+		Top:          key.NewBinding(key.WithKeys("g")),
+		Bottom:       key.NewBinding(key.WithKeys("G")),
+		Mark:         key.NewBinding(key.WithKeys("m")),
+		Comment:      key.NewBinding(key.WithKeys("c")),
+		Submit:       key.NewBinding(key.WithKeys("S")),
+		Quit:         key.NewBinding(key.WithKeys("q")),
+		Help:         key.NewBinding(key.WithKeys("?")),
+		SubmitNote:   key.NewBinding(key.WithKeys("ctrl+s")),
+		Confirm:      key.NewBinding(key.WithKeys("enter")),
+		Escape:       key.NewBinding(key.WithKeys("esc")),
+		ShrinkLeft:   key.NewBinding(key.WithKeys("[")),
+		ExpandLeft:   key.NewBinding(key.WithKeys("]")),
+		Capture:      key.NewBinding(key.WithKeys("r")),
+		CaptureRange: key.NewBinding(key.WithKeys("R")),
+		CaptureNew:   key.NewBinding(key.WithKeys("a")),
+		Follow:       key.NewBinding(key.WithKeys("f")),
+		ClearAll:     key.NewBinding(key.WithKeys("ctrl+r")),
+		PasteToPane:  key.NewBinding(key.WithKeys("P")),
+		ViewNote:     key.NewBinding(key.WithKeys("v")),
+		Visual:       key.NewBinding(key.WithKeys("V")),
+		ExportFormat: key.NewBinding(key.WithKeys("e")),
+		Search:       key.NewBinding(key.WithKeys("/")),
+		SearchBack:   key.NewBinding(key.WithKeys("\\")),
+		SearchNext:   key.NewBinding(key.WithKeys("n")),
+		SearchPrev:   key.NewBinding(key.WithKeys("N")),
+		MarkMatches:  key.NewBinding(key.WithKeys("M")),
+		NoteMatches:  key.NewBinding(key.WithKeys("C")),
+		PanePicker:   key.NewBinding(key.WithKeys("p")),
+		NextBlock:    key.NewBinding(key.WithKeys("}")),
+		PrevBlock:    key.NewBinding(key.WithKeys("{")),
+		Fold:         key.NewBinding(key.WithKeys("z")),
+		FoldAll:      key.NewBinding(key.WithKeys("Z")),
+		DeleteBlock:  key.NewBinding(key.WithKeys("D")),
+		Wrap:         key.NewBinding(key.WithKeys("w")),
+		ScrollLeft:   key.NewBinding(key.WithKeys("h", "left")),
+		ScrollRight:  key.NewBinding(key.WithKeys("l", "right")),
+	}
+	k.refreshHelp()
+	return k
+}
+
+var keys = newKeyMap()
+
+// refreshHelp regenerates each binding's help from its current keys
+func (k *KeyMap) refreshHelp() {
+	for _, d := range k.defs() {
+		d.binding.SetHelp(keyLabel(d.binding.Keys()), d.desc)
+	}
+}
+
+// keyLabel formats keys for display, e.g. ["k", "up"] -> "k/↑"
+func keyLabel(ks []string) string {
+	labels := make([]string, len(ks))
+	for i, k := range ks {
+		switch k {
+		case "up":
+			labels[i] = "↑"
+		case "down":
+			labels[i] = "↓"
+		case "left":
+			labels[i] = "←"
+		case "right":
+			labels[i] = "→"
+		case "esc":
+			labels[i] = "Esc"
+		case "enter":
+			labels[i] = "Enter"
+		default:
+			if rest, ok := strings.CutPrefix(k, "ctrl+"); ok {
+				labels[i] = "Ctrl+" + rest
+			} else {
+				labels[i] = k
+			}
+		}
+	}
+	sep := "/"
+	if strings.Contains(strings.Join(ks, ""), "/") {
+		sep = " "
+	}
+	return strings.Join(labels, sep)
+}
+
+// firstKey returns the primary key of a binding, for inline hints
+func firstKey(b key.Binding) string {
+	if ks := b.Keys(); len(ks) > 0 {
+		return keyLabel(ks[:1])
+	}
+	return ""
+}
+
+// loadKeyMap builds the keymap from defaults plus config overrides
+// (binding name -> keys) and rejects unknown names and conflicting keys.
+func loadKeyMap(overrides map[string][]string) (KeyMap, error) {
+	k := newKeyMap()
+	defs := k.defs()
+
+	byName := make(map[string]keyDef, len(defs))
+	for _, d := range defs {
+		byName[d.name] = d
+	}
+
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []string
+	for _, name := range names {
+		d, ok := byName[name]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("unknown binding %q", name))
+			continue
+		}
+		if len(overrides[name]) == 0 {
+			errs = append(errs, fmt.Sprintf("binding %q has no keys", name))
+			continue
+		}
+		d.binding.SetKeys(overrides[name]...)
+	}
+
+	// every key may belong to only one binding
+	owner := map[string]string{}
+	for _, d := range defs {
+		for _, kk := range d.binding.Keys() {
+			if prev, dup := owner[kk]; dup {
+				errs = append(errs, fmt.Sprintf("key %q bound to both %q and %q", kk, prev, d.name))
+				continue
+			}
+			owner[kk] = d.name
+		}
+	}
+
+	if len(errs) > 0 {
+		return newKeyMap(), fmt.Errorf("keys: %s", strings.Join(errs, "; "))
+	}
+	k.refreshHelp()
+	return k, nil
+}
+
+// bindingsConfig returns the live bindings in config form (for clipnote config)
+func (k *KeyMap) bindingsConfig() map[string][]string {
+	out := map[string][]string{}
+	for _, d := range k.defs() {
+		out[d.name] = d.binding.Keys()
+	}
+	return out
 }
 
-var keys = KeyMap{
-	Up:           key.NewBinding(key.WithKeys("k", "up"), key.WithHelp("k/↑", "up")),
-	Down:         key.NewBinding(key.WithKeys("j", "down"), key.WithHelp("j/↓", "down")),
-	Top:          key.NewBinding(key.WithKeys("g"), key.WithHelp("g", "top")),
-	Bottom:       key.NewBinding(key.WithKeys("G"), key.WithHelp("G", "bottom")),
-	Mark:         key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "mark")),
-	Comment:      key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "mark+note")),
-	Submit:       key.NewBinding(key.WithKeys("S"), key.WithHelp("S", "export")),
-	Quit:         key.NewBinding(key.WithKeys("q"), key.WithHelp("q", "quit")),
-	Help:         key.NewBinding(key.WithKeys("?", "/"), key.WithHelp("?", "help")),
-	SubmitNote:   key.NewBinding(key.WithKeys("ctrl+s")),
-	Escape:       key.NewBinding(key.WithKeys("esc")),
-	ShrinkLeft:   key.NewBinding(key.WithKeys("["), key.WithHelp("[", "shrink")),
-	ExpandLeft:   key.NewBinding(key.WithKeys("]"), key.WithHelp("]", "expand")),
-	Capture:      key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "capture visible")),
-	CaptureRange: key.NewBinding(key.WithKeys("R"), key.WithHelp("R", "custom range")),
-	ClearAll:     key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("ctrl+r", "clear all")),
-	PasteToPane:  key.NewBinding(key.WithKeys("P"), key.WithHelp("P", "paste to left pane")),
-	ViewNote:     key.NewBinding(key.WithKeys("v"), key.WithHelp("v", "view note")),
+// helpLines renders one "keys  description" line per binding
+func (k *KeyMap) helpLines(indent string) []string {
+	defs := k.defs()
+	width := 0
+	for _, d := range defs {
+		width = max(width, len([]rune(d.binding.Help().Key)))
+	}
+	lines := make([]string, len(defs))
+	for i, d := range defs {
+		h := d.binding.Help()
+		pad := width - len([]rune(h.Key)) + 2
+		lines[i] = indent + h.Key + strings.Repeat(" ", pad) + h.Desc
+	}
+	return lines
 }
@@ -1,35 +1,63 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/mattn/go-isatty"
 )
 
+// version is the clipnote release, set at build time with
+// -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
 func main() {
+	// load config file + CLIPNOTE_* env overrides before anything reads cfg
+	loaded, cfgErr := loadConfig()
+	cfg = loaded
+	applyTheme(cfg.Colors)
+	keyMap, keysErr := loadKeyMap(cfg.Keys)
+	keys = keyMap
+	cfgErr = errors.Join(cfgErr, keysErr)
+
 	// Show usage
 	if len(os.Args) >= 2 && (os.Args[1] == "--help" || os.Args[1] == "-h") {
 		printUsage()
 		return
 	}
 
-	// IPC client: clipnote ipc <command> [args...]
-	if len(os.Args) >= 3 && os.Args[1] == "ipc" {
-		command := os.Args[2]
-		args := os.Args[3:]
-		if err := sendIPCCommand(command, args); err != nil {
+	if len(os.Args) >= 2 && os.Args[1] == "--version" {
+		fmt.Printf("clipnote %s (IPC protocol v%d)\n", version, ipcProtocolVersion)
+		return
+	}
+
+	// print effective configuration
+	if len(os.Args) >= 2 && os.Args[1] == "config" {
+		if err := printConfig(cfgErr); err != nil {
+			fmt.Fprintf(os.Stderr, "Config error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// IPC client: clipnote ipc [options] <command> [args...] | -
+	if len(os.Args) >= 2 && os.Args[1] == "ipc" {
+		if err := runIPCClient(os.Args[2:]); err != nil {
 			fmt.Fprintf(os.Stderr, "IPC error: %v\n", err)
 			os.Exit(1)
 		}
 		return
 	}
 
-	// Role 2: annotation panel TUI (invoked internally by tmux split-pane)
-	if len(os.Args) >= 3 && os.Args[1] == "--internal-watch" {
-		paneID := os.Args[2]
-		runAnnotationTUI(paneID)
+	// MCP server: clipnote mcp [--pane <pane>] [--session <id>]
+	if len(os.Args) >= 2 && os.Args[1] == "mcp" {
+		if err := runMCPServer(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "MCP error: %v\n", err)
+			os.Exit(1)
+		}
 		return
 	}
 
@@ -42,12 +70,28 @@ func main() {
 		}
 	}
 
+	// Role 2: annotation panel TUI (invoked internally by tmux split-pane)
+	if len(os.Args) >= 3 && os.Args[1] == "--internal-watch" {
+		paneID := os.Args[2]
+		runAnnotationTUI(paneID, sessionID, cfgErr)
+		return
+	}
+
+	if cfgErr != nil {
+		fmt.Fprintf(os.Stderr, "clipnote: %v (using defaults)\n", cfgErr)
+	}
+
 	// Role 1: launcher
 	runLauncher(sessionID)
 }
 
-func runAnnotationTUI(paneID string) {
-	m := NewWatchModel(paneID)
+func runAnnotationTUI(paneID, sessionID string, cfgErr error) {
+	templateErr := loadTemplateExporters()
+	m := NewWatchModel(paneID, sessionID)
+	// the alt screen hides stderr, so surface startup problems in the status bar
+	if err := errors.Join(cfgErr, templateErr); err != nil {
+		m.statusMsg = strings.ReplaceAll(err.Error(), "\n", "; ")
+	}
 
 	opts := []tea.ProgramOption{tea.WithAltScreen()}
 	p := tea.NewProgram(m, opts...)
@@ -55,7 +99,9 @@ func runAnnotationTUI(paneID string) {
 	// expose program to IPC handler so it can inject messages
 	ipcProgram = p
 
-	if _, err := p.Run(); err != nil {
+	_, err := p.Run()
+	stopIPCServer()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "TUI error: %v\n", err)
 		os.Exit(1)
 	}
@@ -73,7 +119,7 @@ func runLauncher(sessionID string) {
 
 	clis := detectCLIs()
 	if len(clis) == 0 {
-		fmt.Fprintln(os.Stderr, "No installed AI CLI found (claude, gemini, codex, aider)")
+		fmt.Fprintf(os.Stderr, "No installed AI CLI found (%s)\n", strings.Join(cfg.CLIs, ", "))
 		os.Exit(1)
 	}
 
@@ -101,26 +147,61 @@ func printUsage() {
 Usage:
   clipnote                          Launch tmux session (auto-detect AI CLI)
   clipnote --session-id <uuid>      Launch with claude --resume <uuid>
-  clipnote ipc <cmd>                Send IPC command to running annotation TUI
+  clipnote ipc [--pane <pane>] [--session <id>] [--pretty] [--format json|text] <cmd>
+                                    Send IPC command to a running annotation TUI
+  clipnote ipc [options] [--atomic] -
+                                    Send NDJSON requests read from stdin
+  clipnote mcp [--pane <pane>] [--session <id>]
+                                    Serve the IPC commands as MCP tools over stdio
+  clipnote config                   Print the effective configuration
+  clipnote --version                Show the version and IPC protocol version
   clipnote --help                   Show this help
 
+Configuration: $XDG_CONFIG_HOME/clipnote/config.json (or $CLIPNOTE_CONFIG).
+Every field except "colors" and "keys" can be overridden by an env var, e.g.
+CLIPNOTE_SPLIT_RATIO, CLIPNOTE_PANE_SPLIT, CLIPNOTE_SESSION_NAME, CLIPNOTE_CLIS.
+Colors and keys are set in the file only; keys are rebound with a "keys"
+object, e.g. {"keys": {"mark": ["m", "space"]}}.
+
+Captures and marks are saved under $XDG_STATE_HOME/clipnote/ and restored
+when the annotation panel is reopened.
+
+Custom export formats: each $XDG_CONFIG_HOME/clipnote/templates/<name>.tmpl
+(Go text/template) adds format <name>. Templates range over .Marks, each with
+.Line .End .Label .Text .Lines .Note .Capture .Pane .Time .Mode .Source
+.Before .After (context lines stop at the mark's capture block).
+
+IPC commands go to the instance watching --pane (e.g. %3) or --session;
+without either, to the only running instance or the one watching $TMUX_PANE.
+Sockets live in $XDG_RUNTIME_DIR/clipnote/ (or /tmp/clipnote-<uid>/).
+Responses are NDJSON; --pretty indents them and --format text prints them
+as plain text. "ipc -" sends one JSON request per stdin line over a single
+connection and prints one response per line; with --atomic the requests run
+as one batch that stops at the first error. Exits 1 if any request failed.
+
 IPC commands:
-  capture               Capture left pane content
+  hello                 Show the TUI's version, protocol and supported commands
+  list                  List running instances (pane, session, pid, socket)
+  capture [--mode visible|lines|range|all|new] [--lines N] [--start N] [--end N]
+                        Capture the current source pane (the watched pane
+                        unless another was picked with p) like the capture keys
+  get-captures          List capture blocks (pane, time, mode, line span)
   get-marks             Get all marks as JSON
+  get-lines [--start N] [--end N] [--capture N] [--pattern RE] [--limit N]
+                        Get captured lines with their 0-indexed line numbers
+                        (500 per page by default; "next" starts the next page)
   mark <line> [line...] Mark specific lines (0-indexed)
-  export                Export marks to clipboard
-
-Keybindings (in annotation panel):
-  r       Capture left pane content
-  R       Custom range capture
-  Ctrl+r  Clear all content and marks
-  j/k     Move up/down
-  g/G     Jump to top/bottom
-  m       Toggle mark
-  c       Mark + annotate
-  S       Export marks to clipboard
-  P       Paste marks to left pane
-  [/]     Shrink/expand content panel
-  ?       Show help
-  q       Quit`)
+  unmark <line> [line...]
+                        Remove the marks covering these lines
+  note <line> <text>    Set the note on a line's mark (marks it if needed)
+  clear-marks           Remove all marks
+  clear                 Remove all captured content and marks
+  mark-pattern <regex> [note]
+                        Mark every line matching regex, optionally with a note
+  export [format]       Export marks to clipboard (quote, markdown, review, json, xml)
+  subscribe [event...]  Stream events as NDJSON until the TUI exits (mark-added,
+                        mark-removed, note-changed, captured, exported, cleared)
+
+Keybindings (in annotation panel):`)
+	fmt.Println(strings.Join(keys.helpLines("  "), "\n"))
 }
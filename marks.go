@@ -5,13 +5,67 @@ import (
 	"strings"
 )
 
-const noteExportPrefix = "[Q] "
-
-// Mark represents a user's annotation on a specific line
+// Mark represents a user's annotation on a line or a contiguous line range
 type Mark struct {
-	Line int
-	Text string // original line text (truncated to 60 chars)
-	Note string // user's annotation
+	Line    int    `json:"line"`
+	End     int    `json:"end,omitempty"`     // last line of a range mark (0 = single line)
+	Text    string `json:"text"`              // full original line text (newline-joined for ranges)
+	Note    string `json:"note,omitempty"`    // user's annotation
+	Capture int    `json:"capture,omitempty"` // capture number (#N) the line came from
+	Pane    string `json:"pane,omitempty"`    // pane that capture was taken from
+}
+
+// Last returns the last line covered by the mark
+func (mk Mark) Last() int {
+	if mk.End > mk.Line {
+		return mk.End
+	}
+	return mk.Line
+}
+
+// IsRange reports whether the mark spans more than one line
+func (mk Mark) IsRange() bool {
+	return mk.End > mk.Line
+}
+
+// Covers reports whether line falls within the mark
+func (mk Mark) Covers(line int) bool {
+	return line >= mk.Line && line <= mk.Last()
+}
+
+// Label returns the 1-indexed line label shown in the UI (e.g. "L3" or "L3-10")
+func (mk Mark) Label() string {
+	if mk.IsRange() {
+		return fmt.Sprintf("L%d-%d", mk.Line+1, mk.Last()+1)
+	}
+	return fmt.Sprintf("L%d", mk.Line+1)
+}
+
+// markText returns the full text of lines start..end
+func (m *Model) markText(start, end int) string {
+	return strings.Join(m.lines[start:end+1], "\n")
+}
+
+// captureOf returns the capture number (#N) that line belongs to, or 0 if unknown
+func (m *Model) captureOf(line int) int {
+	return blockIndexIn(m.captures, line) + 1
+}
+
+// newMark builds a mark for lines start..end with its text and capture provenance
+func (m *Model) newMark(start, end int) Mark {
+	mk := Mark{Line: start, Text: m.markText(start, end), Capture: m.captureOf(start)}
+	if mk.Capture > 0 {
+		mk.Pane = m.captures[mk.Capture-1].Pane
+	}
+	if end > start {
+		mk.End = end
+	}
+	return mk
+}
+
+// markable reports whether line is captured content that can hold a mark
+func (m *Model) markable(line int) bool {
+	return line >= 0 && line < len(m.lines) && !m.isHeader(line)
 }
 
 func (m *Model) ToggleMark(line int) {
@@ -19,34 +73,136 @@ func (m *Model) ToggleMark(line int) {
 		m.RemoveMark(line)
 		return
 	}
-	text := truncate(m.lines[line], 60)
-	m.marks = append(m.marks, Mark{Line: line, Text: text})
+	if !m.markable(line) {
+		return
+	}
+	mk := m.appendMark(line, "")
+	m.saveSession()
+	publish(eventMarkAdded, newMarkData(mk))
+}
+
+// appendMark adds a single-line mark without saving or publishing, for
+// callers that change many marks and commit them once
+func (m *Model) appendMark(line int, note string) Mark {
+	mk := m.newMark(line, line)
+	mk.Note = note
+	m.marks = append(m.marks, mk)
+	return mk
+}
+
+// publishMarks sends event for each of marks
+func publishMarks(event string, marks []Mark) {
+	for _, mk := range marks {
+		publish(event, newMarkData(mk))
+	}
 }
 
 func (m *Model) AddMarkWithNote(line int, note string) {
+	if !m.markable(line) {
+		return
+	}
 	// update note if mark already exists
 	for i, mk := range m.marks {
-		if mk.Line == line {
+		if mk.Covers(line) {
 			m.marks[i].Note = note
+			m.saveSession()
+			publish(eventNoteChanged, newMarkData(m.marks[i]))
 			return
 		}
 	}
-	text := truncate(m.lines[line], 60)
-	m.marks = append(m.marks, Mark{Line: line, Text: text, Note: note})
+	mk := m.appendMark(line, note)
+	m.saveSession()
+	publish(eventMarkAdded, newMarkData(mk))
+}
+
+// AddRangeMark marks lines start..end as a single range.
+// Existing marks overlapping the range are replaced by it. It reports false,
+// changing nothing, if the range is out of bounds or crosses a capture header.
+func (m *Model) AddRangeMark(start, end int) bool {
+	if start > end {
+		start, end = end, start
+	}
+	if !m.markable(start) || !m.markable(end) || blockIndexIn(m.captures, start) != blockIndexIn(m.captures, end) {
+		return false
+	}
+	var removed []Mark
+	kept := m.marks[:0]
+	for _, mk := range m.marks {
+		if mk.Last() < start || mk.Line > end {
+			kept = append(kept, mk)
+		} else {
+			removed = append(removed, mk)
+		}
+	}
+	mk := m.newMark(start, end)
+	m.marks = append(kept, mk)
+	m.saveSession()
+	publishMarks(eventMarkRemoved, removed)
+	publish(eventMarkAdded, newMarkData(mk))
+	return true
 }
 
+// MarkLines marks every given line, attaching note when it is non-empty.
+// Lines that are already marked keep their mark; its note is only set if it
+// has none, so notes the user wrote are never replaced. The session is saved
+// once for the whole batch. It returns the number of newly marked lines and
+// of existing marks that received the note.
+func (m *Model) MarkLines(lines []int, note string) (marked, noted int) {
+	var added, changed []Mark
+	for _, line := range lines {
+		if !m.markable(line) {
+			continue
+		}
+		if mk := m.GetMark(line); mk != nil {
+			// a range mark is noted once, however many of its lines match
+			if note != "" && mk.Note == "" {
+				mk.Note = note
+				changed = append(changed, *mk)
+			}
+			continue
+		}
+		added = append(added, m.appendMark(line, note))
+	}
+	if len(added) > 0 || len(changed) > 0 {
+		m.saveSession()
+	}
+	publishMarks(eventMarkAdded, added)
+	publishMarks(eventNoteChanged, changed)
+	return len(added), len(changed)
+}
+
+// RemoveMark removes the mark covering line
 func (m *Model) RemoveMark(line int) {
+	if mk, ok := m.dropMark(line); ok {
+		m.saveSession()
+		publish(eventMarkRemoved, newMarkData(mk))
+	}
+}
+
+// dropMark removes the mark covering line without saving or publishing
+func (m *Model) dropMark(line int) (Mark, bool) {
 	for i, mk := range m.marks {
-		if mk.Line == line {
+		if mk.Covers(line) {
 			m.marks = append(m.marks[:i], m.marks[i+1:]...)
-			return
+			return mk, true
 		}
 	}
+	return Mark{}, false
+}
+
+// ClearMarks removes every mark and returns how many there were
+func (m *Model) ClearMarks() int {
+	removed := m.marks
+	m.marks = []Mark{}
+	m.saveSession()
+	publishMarks(eventMarkRemoved, removed)
+	return len(removed)
 }
 
+// GetMark returns the mark covering line, or nil
 func (m Model) GetMark(line int) *Mark {
 	for i := range m.marks {
-		if m.marks[i].Line == line {
+		if m.marks[i].Covers(line) {
 			return &m.marks[i]
 		}
 	}
@@ -55,31 +211,22 @@ func (m Model) GetMark(line int) *Mark {
 
 func (m *Model) HasMark(line int) bool {
 	for _, mk := range m.marks {
-		if mk.Line == line {
+		if mk.Covers(line) {
 			return true
 		}
 	}
 	return false
 }
 
-func (m *Model) ExportMarks() string {
-	if len(m.marks) == 0 {
-		return ""
-	}
-	var sb strings.Builder
-	for _, mk := range m.marks {
-		sb.WriteString(mk.Text + "\n")
-		if mk.Note != "" {
-			sb.WriteString(fmt.Sprintf("> %s%s\n", noteExportPrefix, mk.Note))
-		}
-	}
-	return strings.TrimSpace(sb.String())
+// ExportMarks renders all marks with the currently selected export format
+func (m *Model) ExportMarks() (string, error) {
+	return m.ExportMarksAs(m.exporter())
 }
 
-func truncate(s string, maxLen int) string {
-	runes := []rune(s)
-	if len(runes) <= maxLen {
-		return s
+// ExportMarksAs renders all marks with the given exporter
+func (m *Model) ExportMarksAs(e Exporter) (string, error) {
+	if len(m.marks) == 0 {
+		return "", nil
 	}
-	return string(runes[:maxLen]) + "..."
+	return e.Export(m.marks, m.lines, m.captures)
 }
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// MCP protocol revisions this server can speak, newest first
+var mcpProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // absent for notifications
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// mcpTool is an MCP tool backed by one IPC command
+type mcpTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema map[string]any  `json:"inputSchema"`
+	Annotations *mcpAnnotations `json:"annotations,omitempty"`
+
+	command string // IPC command the tool sends
+}
+
+type mcpAnnotations struct {
+	ReadOnly    bool `json:"readOnlyHint"`
+	Destructive bool `json:"destructiveHint"`
+}
+
+type mcpContent struct {
+	Type string `json:"type"` // always "text"
+	Text string `json:"text"`
+}
+
+type mcpToolResult struct {
+	Content []mcpContent `json:"content"`
+	IsError bool         `json:"isError,omitempty"`
+}
+
+// mcpSchema builds a JSON schema for a tool's arguments
+func mcpSchema(props map[string]any, required ...string) map[string]any {
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+var (
+	mcpIntProp   = map[string]any{"type": "integer"}
+	mcpLinesProp = map[string]any{"type": "array", "items": mcpIntProp, "description": "0-indexed line numbers as returned by get_lines"}
+)
+
+// mcpTools maps MCP tools onto the IPC commands handled by handleIPC
+var mcpTools = []mcpTool{
+	{
+		Name:        "capture",
+		Description: "Capture pane content into the annotation panel, like the capture keys. Reads the pane the user picked as capture source (the AI pane unless they picked another); the result's pane field tells which. Returns the number of lines added.",
+		InputSchema: mcpSchema(map[string]any{
+			"mode": map[string]any{"type": "string", "enum": captureModes,
+				"description": "visible (default): the visible area; lines: N lines of history, like the capture-range key; range: tmux lines start..end; all: the whole scrollback; new: output since the previous capture"},
+			"lines": map[string]any{"type": "integer", "description": "line count for lines mode"},
+			"start": map[string]any{"type": "integer", "description": "range mode: first tmux line (0 = top of the visible area, negative = history)"},
+			"end":   map[string]any{"type": "integer", "description": "range mode: last tmux line"},
+		}),
+		command: "capture",
+	},
+	{
+		Name:        "get_lines",
+		Description: "Read captured lines with their 0-indexed line numbers, optionally limited to a range, a capture or a regex. Returns at most 500 lines unless limit is given; when more remain, next is the start of the following page.",
+		InputSchema: mcpSchema(map[string]any{
+			"start":   map[string]any{"type": "integer", "description": "first line (0-indexed)"},
+			"end":     map[string]any{"type": "integer", "description": "last line, inclusive"},
+			"capture": map[string]any{"type": "integer", "description": "only lines of capture #N"},
+			"pattern": map[string]any{"type": "string", "description": "only lines matching this regex"},
+			"limit":   map[string]any{"type": "integer", "description": "max lines returned (default 500)"},
+		}),
+		Annotations: &mcpAnnotations{ReadOnly: true},
+		command:     "get-lines",
+	},
+	{
+		Name:        "get_captures",
+		Description: "List the capture blocks: source pane, time, capture mode and the 0-indexed lines each one spans.",
+		InputSchema: mcpSchema(map[string]any{}),
+		Annotations: &mcpAnnotations{ReadOnly: true},
+		command:     "get-captures",
+	},
+	{
+		Name:        "get_marks",
+		Description: "List the lines the user has marked, with their notes.",
+		InputSchema: mcpSchema(map[string]any{}),
+		Annotations: &mcpAnnotations{ReadOnly: true},
+		command:     "get-marks",
+	},
+	{
+		Name:        "mark",
+		Description: "Mark captured lines so the user sees them highlighted.",
+		InputSchema: mcpSchema(map[string]any{"lines": mcpLinesProp}, "lines"),
+		command:     "mark",
+	},
+	{
+		Name:        "unmark",
+		Description: "Remove the marks covering the given lines.",
+		InputSchema: mcpSchema(map[string]any{"lines": mcpLinesProp}, "lines"),
+		Annotations: &mcpAnnotations{Destructive: true},
+		command:     "unmark",
+	},
+	{
+		Name:        "note",
+		Description: "Set the note on a line's mark, marking the line first if needed.",
+		InputSchema: mcpSchema(map[string]any{
+			"line": map[string]any{"type": "integer", "description": "0-indexed line number"},
+			"note": map[string]any{"type": "string"},
+		}, "line", "note"),
+		command: "note",
+	},
+	{
+		Name:        "mark_pattern",
+		Description: "Mark every captured line matching a regex, optionally with a note. Notes already on marked lines are kept; noted counts the marks without one that got it.",
+		InputSchema: mcpSchema(map[string]any{
+			"pattern": map[string]any{"type": "string", "description": "Go regular expression (RE2); prefix (?i) to ignore case"},
+			"note":    map[string]any{"type": "string"},
+		}, "pattern"),
+		command: "mark-pattern",
+	},
+	{
+		Name:        "clear_marks",
+		Description: "Remove all marks. Captured content is kept.",
+		InputSchema: mcpSchema(map[string]any{}),
+		Annotations: &mcpAnnotations{Destructive: true},
+		command:     "clear-marks",
+	},
+	{
+		Name:        "export",
+		Description: "Export the marked lines and notes (also copied to the clipboard).",
+		InputSchema: mcpSchema(map[string]any{
+			"format": map[string]any{"type": "string", "description": "quote, markdown, review, json, xml or a custom template; defaults to the TUI's current format"},
+		}),
+		command: "export",
+	},
+}
+
+// runMCPServer serves the IPC commands as MCP tools over stdio (newline
+// delimited JSON-RPC). Each tool call is sent to the instance selected by
+// --pane/--session at call time, so the TUI may be started or restarted
+// while the server runs.
+func runMCPServer(args []string) error {
+	opts, rest, err := parseIPCOptions(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("unexpected argument: %s (mcp takes only --pane and --session)", rest[0])
+	}
+	return serveMCP(os.Stdin, os.Stdout, opts)
+}
+
+func serveMCP(r io.Reader, w io.Writer, opts ipcOptions) error {
+	out := json.NewEncoder(w)
+	scanner := newLineScanner(r)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var req rpcRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			out.Encode(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"),
+				Error: &rpcError{Code: rpcParseError, Message: "invalid JSON-RPC message"}})
+			continue
+		}
+		if req.ID == nil {
+			// notifications (initialized, cancelled) need no answer
+			continue
+		}
+
+		result, rpcErr := handleMCP(req, opts)
+		if err := out.Encode(rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rpcErr}); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func handleMCP(req rpcRequest, opts ipcOptions) (any, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+		protocol := mcpProtocolVersions[0]
+		if slices.Contains(mcpProtocolVersions, params.ProtocolVersion) {
+			protocol = params.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": protocol,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "clipnote", "version": version},
+			"instructions": "clipnote shows the AI pane's output in a tmux side panel where the user marks lines and adds notes. " +
+				"Call capture before get_lines; line numbers are 0-indexed. Tools fail if no annotation panel is running.",
+		}, nil
+
+	case "ping":
+		return map[string]any{}, nil
+
+	case "tools/list":
+		return map[string]any{"tools": mcpTools}, nil
+
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "invalid tools/call params"}
+		}
+		i := slices.IndexFunc(mcpTools, func(t mcpTool) bool { return t.Name == params.Name })
+		if i < 0 {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "unknown tool: " + params.Name}
+		}
+		return callMCPTool(mcpTools[i], params.Arguments, opts), nil
+
+	case "":
+		return nil, &rpcError{Code: rpcInvalidRequest, Message: "missing method"}
+	default:
+		return nil, &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + req.Method}
+	}
+}
+
+// callMCPTool sends the tool's IPC command and turns the response into a tool
+// result; IPC failures are reported as tool errors so the model can see them
+func callMCPTool(tool mcpTool, arguments json.RawMessage, opts ipcOptions) mcpToolResult {
+	// tool arguments use the IPC request field names, except note's single line
+	var args struct {
+		ipcRequest
+		Line *int `json:"line"`
+	}
+	if len(arguments) > 0 {
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return mcpErrorResult("invalid arguments: %v", err)
+		}
+	}
+	req := args.ipcRequest
+	req.V, req.ID, req.Type = 0, nil, tool.command
+	if args.Line != nil {
+		req.Lines = []int{*args.Line}
+	}
+
+	socket, err := resolveSocket(opts.pane, opts.sessionID)
+	if err != nil {
+		return mcpErrorResult("%v", err)
+	}
+	c, err := dialIPC(socket)
+	if err != nil {
+		return mcpErrorResult("%v", err)
+	}
+	defer c.Close()
+	if err := c.checkCommand(req.Type); err != nil {
+		return mcpErrorResult("%v", err)
+	}
+
+	line, err := c.roundTrip(req)
+	if err != nil {
+		return mcpErrorResult("%v", err)
+	}
+	var resp struct {
+		ipcResponse
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return mcpErrorResult("invalid response from clipnote: %v", err)
+	}
+	if resp.Type == "error" {
+		return mcpErrorResult("%s (%s)", resp.Message, resp.Code)
+	}
+	text := string(resp.Data)
+	if text == "" {
+		text = "{}"
+	}
+	return mcpToolResult{Content: []mcpContent{{Type: "text", Text: text}}}
+}
+
+func mcpErrorResult(format string, args ...any) mcpToolResult {
+	return mcpToolResult{Content: []mcpContent{{Type: "text", Text: fmt.Sprintf(format, args...)}}, IsError: true}
+}
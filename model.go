@@ -1,8 +1,10 @@
 package main
 
 import (
+	"cmp"
 	"fmt"
 	"os/exec"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -15,7 +17,17 @@ var execCommand = exec.Command
 const noteInputHeight = 4
 
 // CaptureAppendMsg is the message type for capture results
-type CaptureAppendMsg string
+type CaptureAppendMsg struct {
+	Content     string    // plain captured text
+	Styled      []string  // per-line text with ANSI styles ("" = same as plain); nil if none
+	Tail        *paneTail // pane end position at capture time (nil = unknown, keep previous)
+	Incremental bool      // Content holds only output since the previous capture (may be empty)
+	Failed      bool      // Content is an error message
+	Pane        string    // pane the content was captured from
+	Mode        string    // capture mode (see captureModes)
+	IPC         bool      // requested over IPC rather than with a key
+	FollowGen   int       // non-zero for follow-mode polls (see followTickMsg)
+}
 
 type overlayKind int
 
@@ -23,133 +35,203 @@ const (
 	overlayNone overlayKind = iota
 	overlayHelp
 	overlayNote
+	overlayPanes
 )
 
 type Model struct {
-	lines     []string
-	noteInput textarea.Model
-	marks      []Mark
-	cursorLine int
-	inputMode  bool
-	overlayType overlayKind
-	width      int
-	height     int
-	statusMsg  string
-	ready      bool
-	splitRatio   int // left content panel width percentage (default 70)
-	scrollOffset int // manually managed scroll offset
-
-	tmuxPane       string // left pane tmux ID (e.g. clipnote:0.0)
-	captureCount   int    // capture counter for separator lines (#N)
-	captureInput   bool   // whether R line count input mode is active
-	captureInputBuf string // R input buffer text
-	captureConfirm bool   // whether confirming full scrollback capture
-}
-
-func NewWatchModel(paneID string) Model {
+	lines        []string
+	styled       []string // ANSI-styled version of each line ("" = plain only), parallel to lines
+	noteInput    textarea.Model
+	marks        []Mark
+	cursorLine   int
+	inputMode    bool
+	overlayType  overlayKind
+	width        int
+	height       int
+	statusMsg    string
+	ready        bool
+	splitRatio   int  // left content panel width percentage (config split_ratio, default 70)
+	scrollOffset int  // first line shown in the content panel
+	wrap         bool // whether long lines wrap onto several rows (config wrap)
+	hscroll      int  // cells scrolled right, when not wrapping
+
+	tmuxPane        string          // watched pane tmux ID (e.g. clipnote:0.0), keys the store and socket
+	watchedID       string          // tmuxPane as a pane ID (%N) for comparing with other panes; "" if unresolved
+	captureCount    int             // capture counter for separator lines (#N)
+	captures        []captureBlock  // capture blocks, indexed by #N-1
+	sources         []captureSource // panes captured from; sources[0] is the watched pane
+	source          int             // index of the pane captures currently read from
+	captureInput    bool            // whether R line count input mode is active
+	captureInputBuf string          // R input buffer text
+	captureConfirm  bool            // whether confirming full scrollback capture
+
+	pickerPanes  []paneInfo // panes listed by the pane picker overlay
+	pickerCursor int        // selected row in the pane picker
+
+	following  bool // whether follow mode streams new pane output
+	followGen  int  // current follow session, to drop ticks from earlier sessions
+	followOpen bool // whether the last capture block is a follow block that polls extend
+
+	exportFormat string // name of the selected exporter (see exporters)
+
+	visualMode   bool // whether visual line selection (V) is active
+	visualAnchor int  // line where the visual selection started
+
+	searchInput    bool           // whether the search prompt is active
+	searchBuf      string         // search prompt text
+	searchBackward bool           // whether the search runs backward (n goes up)
+	searchOrigin   int            // cursor line when the prompt was opened
+	searchPattern  string         // active search pattern (highlighted, n/N)
+	searchRe       *regexp.Regexp // compiled searchPattern (nil = no search)
+	searchMatches  []int          // lines matching searchRe, ascending
+	noteMatches    bool           // whether the note being typed goes to every search match
+
+	sessionID string // conversation session ID (keys the store and IPC socket)
+	storePath string // session snapshot file (empty = persistence disabled)
+}
+
+// NewWatchModel creates the annotation model and restores any saved session
+// for the watched pane (or the conversation session ID, when given)
+func NewWatchModel(paneID, sessionID string) Model {
 	ta := textarea.New()
 	ta.Placeholder = "Enter note..."
-	ta.CharLimit = 500
+	ta.CharLimit = cfg.NoteCharLimit
 	ta.SetHeight(noteInputHeight)
 	ta.ShowLineNumbers = false
 
-	return Model{
-		lines:      []string{},
-		noteInput:  ta,
-		marks:      []Mark{},
-		splitRatio: 70,
-		tmuxPane:   paneID,
+	// list-panes and captures report %N IDs, while the launcher watches a
+	// target such as clipnote:0.0
+	watchedID := tmuxDisplayVar(paneID, "pane_id")
+
+	m := Model{
+		lines:        []string{},
+		noteInput:    ta,
+		marks:        []Mark{},
+		splitRatio:   cfg.SplitRatio,
+		wrap:         cfg.Wrap,
+		tmuxPane:     paneID,
+		watchedID:    watchedID,
+		sources:      []captureSource{{Pane: cmp.Or(watchedID, paneID)}},
+		sessionID:    sessionID,
+		storePath:    sessionStorePath(storePaneKey(paneID), sessionID),
+		exportFormat: cfg.ExportFormat,
 	}
+	m.loadSession()
+	return m
 }
 
 func (m Model) Init() tea.Cmd {
-	return startIPCServer()
+	return startIPCServer(m.tmuxPane, m.watchedID, m.sessionID)
 }
 
-// captureVisible captures the visible area of the left pane (scroll-position aware)
-func captureVisible(paneID string) tea.Cmd {
-	return func() tea.Msg {
-		scrollPos := tmuxDisplayVar(paneID, "scroll_position")
-		if scrollPos == "" || scrollPos == "0" {
-			return captureExec(paneID, nil)
-		}
+// capture modes shared by the capture keys and the IPC capture command
+const (
+	captureModeVisible = "visible" // the visible area, following copy-mode scrolling
+	captureModeLines   = "lines"   // N lines ending at the bottom of the visible area
+	captureModeRange   = "range"   // an explicit tmux line range
+	captureModeAll     = "all"     // the entire scrollback
+	captureModeNew     = "new"     // output since the previous capture
+)
 
-		sp, err := strconv.Atoi(scrollPos)
-		if err != nil {
-			return captureExec(paneID, nil)
-		}
+var captureModes = []string{captureModeVisible, captureModeLines, captureModeRange, captureModeAll, captureModeNew}
 
-		ph, err := strconv.Atoi(tmuxDisplayVar(paneID, "pane_height"))
-		if err != nil || ph <= 0 {
-			return captureExec(paneID, nil)
-		}
+// captureSpec describes what to capture from the watched pane
+type captureSpec struct {
+	Mode  string
+	Lines int  // captureModeLines: line count
+	Start *int // captureModeRange: tmux coordinates (0 = top of the visible
+	End   *int // area, negative = history); nil = tmux default
+}
 
-		// visible top = -sp, visible bottom = ph - 1 - sp
-		startLine := -sp
-		endLine := ph - 1 - sp
-		return captureExec(paneID, []string{
-			"-S", strconv.Itoa(startLine),
-			"-E", strconv.Itoa(endLine),
-		})
-	}
+// capturePane captures the watched pane as described by spec. Both the
+// capture keys (through the tea.Cmd wrappers below) and the IPC capture
+// command use it; tail is the previous capture's end for captureModeNew.
+func capturePane(paneID string, spec captureSpec, tail *paneTail) CaptureAppendMsg {
+	msg := spec.run(paneID, tail)
+	msg.Pane, msg.Mode = paneID, spec.Mode
+	return msg
 }
 
-// captureRange captures N lines from the left pane (scroll-position aware)
-func captureRange(paneID string, lines int) tea.Cmd {
-	return func() tea.Msg {
-		scrollPos := tmuxDisplayVar(paneID, "scroll_position")
-		if scrollPos == "" || scrollPos == "0" {
-			// not scrolled, capture n lines from bottom
-			return captureExec(paneID, []string{"-S", fmt.Sprintf("-%d", lines)})
+func (spec captureSpec) run(paneID string, tail *paneTail) CaptureAppendMsg {
+	switch spec.Mode {
+	case captureModeLines:
+		if _, bottom, ok := visibleBounds(paneID); ok {
+			// scrolled: N lines upward from the visible bottom
+			return captureExec(paneID, []string{
+				"-S", strconv.Itoa(bottom - spec.Lines + 1),
+				"-E", strconv.Itoa(bottom),
+			})
 		}
+		// not scrolled, capture n lines from bottom
+		return captureExec(paneID, []string{"-S", fmt.Sprintf("-%d", spec.Lines)})
 
-		sp, err := strconv.Atoi(scrollPos)
-		if err != nil {
-			return captureExec(paneID, []string{"-S", fmt.Sprintf("-%d", lines)})
+	case captureModeRange:
+		var extra []string
+		if spec.Start != nil {
+			extra = append(extra, "-S", strconv.Itoa(*spec.Start))
 		}
+		if spec.End != nil {
+			extra = append(extra, "-E", strconv.Itoa(*spec.End))
+		}
+		return captureExec(paneID, extra)
+
+	case captureModeAll:
+		return captureExec(paneID, []string{"-S", "-"})
 
-		ph, err2 := strconv.Atoi(tmuxDisplayVar(paneID, "pane_height"))
-		if err2 != nil || ph <= 0 {
-			return captureExec(paneID, []string{"-S", fmt.Sprintf("-%d", lines)})
+	case captureModeNew:
+		if tail != nil {
+			return captureNewOutput(paneID, tail)
 		}
+	}
 
-		// visible bottom = ph - 1 - sp, capture N lines upward
-		endLine := ph - 1 - sp
-		startLine := endLine - lines + 1
+	if top, bottom, ok := visibleBounds(paneID); ok {
 		return captureExec(paneID, []string{
-			"-S", strconv.Itoa(startLine),
-			"-E", strconv.Itoa(endLine),
+			"-S", strconv.Itoa(top),
+			"-E", strconv.Itoa(bottom),
 		})
 	}
+	return captureExec(paneID, nil)
 }
 
-// captureAll captures the entire scrollback of the left pane
-func captureAll(paneID string) tea.Cmd {
-	return capturePane(paneID, "-")
+// visibleBounds returns the visible area in tmux coordinates when the pane is
+// scrolled back in copy mode (ok is false when it is not, or on error)
+func visibleBounds(paneID string) (top, bottom int, ok bool) {
+	scrollPos := tmuxDisplayVar(paneID, "scroll_position")
+	if scrollPos == "" || scrollPos == "0" {
+		return 0, 0, false
+	}
+	sp, err := strconv.Atoi(scrollPos)
+	if err != nil {
+		return 0, 0, false
+	}
+	ph, err := strconv.Atoi(tmuxDisplayVar(paneID, "pane_height"))
+	if err != nil || ph <= 0 {
+		return 0, 0, false
+	}
+	// visible top = -sp, visible bottom = ph - 1 - sp
+	return -sp, ph - 1 - sp, true
 }
 
-// capturePane runs tmux capture-pane and returns the result
-func capturePane(paneID, startLine string) tea.Cmd {
+// captureCmd runs capturePane in the background
+func captureCmd(paneID string, spec captureSpec, tail *paneTail) tea.Cmd {
 	return func() tea.Msg {
-		args := []string{"capture-pane", "-p", "-t", paneID}
-		if startLine != "" {
-			args = append(args, "-S", startLine)
-		}
+		return capturePane(paneID, spec, tail)
+	}
+}
 
-		cmd := execCommand("tmux", args...)
-		out, err := cmd.CombinedOutput()
-		if err != nil {
-			errMsg := strings.TrimSpace(string(out))
-			if errMsg == "" {
-				errMsg = err.Error()
-			}
-			return CaptureAppendMsg("Capture failed:\n" + errMsg)
-		}
+// captureVisible captures the visible area of the left pane (scroll-position aware)
+func captureVisible(paneID string) tea.Cmd {
+	return captureCmd(paneID, captureSpec{Mode: captureModeVisible}, nil)
+}
 
-		// trim trailing empty lines
-		result := strings.TrimRight(string(out), "\n")
-		return CaptureAppendMsg(result)
-	}
+// captureRange captures N lines from the left pane (scroll-position aware)
+func captureRange(paneID string, lines int) tea.Cmd {
+	return captureCmd(paneID, captureSpec{Mode: captureModeLines, Lines: lines}, nil)
+}
+
+// captureAll captures the entire scrollback of the left pane
+func captureAll(paneID string) tea.Cmd {
+	return captureCmd(paneID, captureSpec{Mode: captureModeAll}, nil)
 }
 
 // tmuxDisplayVar queries a tmux pane format variable
@@ -162,17 +244,92 @@ func tmuxDisplayVar(paneID, varName string) string {
 	return strings.TrimSpace(string(out))
 }
 
-// captureExec runs tmux capture-pane and returns the result (used by captureVisible)
-func captureExec(paneID string, extraArgs []string) tea.Msg {
-	args := []string{"capture-pane", "-p", "-t", paneID}
-	args = append(args, extraArgs...)
-	out, err := execCommand("tmux", args...).CombinedOutput()
+// captureExec runs tmux capture-pane and returns the result
+func captureExec(paneID string, extraArgs []string) CaptureAppendMsg {
+	out, err := execCommand("tmux", captureArgs(paneID, extraArgs...)...).CombinedOutput()
 	if err != nil {
 		errMsg := strings.TrimSpace(string(out))
 		if errMsg == "" {
 			errMsg = err.Error()
 		}
-		return CaptureAppendMsg("Capture failed:\n" + errMsg)
+		return CaptureAppendMsg{Content: "Capture failed:\n" + errMsg, Failed: true}
+	}
+	return newCaptureMsg(string(out), readTail(paneID))
+}
+
+// newCaptureMsg builds a capture result from raw capture-pane output
+func newCaptureMsg(raw string, tail *paneTail) CaptureAppendMsg {
+	plain, styled := splitStyled(strings.TrimRight(raw, "\n"))
+	// trim trailing empty lines
+	for len(plain) > 1 && plain[len(plain)-1] == "" {
+		plain, styled = plain[:len(plain)-1], styled[:len(styled)-1]
+	}
+	return CaptureAppendMsg{Content: strings.Join(plain, "\n"), Styled: styled, Tail: tail}
+}
+
+// appendLines appends captured lines, keeping styled parallel to lines
+func (m *Model) appendLines(plain, styled []string) {
+	// backfill for sessions restored without styled lines
+	for len(m.styled) < len(m.lines) {
+		m.styled = append(m.styled, "")
+	}
+	m.lines = append(m.lines, plain...)
+	for i := range plain {
+		s := ""
+		if i < len(styled) {
+			s = styled[i]
+		}
+		m.styled = append(m.styled, s)
+	}
+	m.refreshSearch()
+}
+
+// clearAll drops all captured content and marks
+func (m *Model) clearAll() {
+	m.lines = []string{}
+	m.styled = nil
+	m.marks = []Mark{}
+	m.captureCount = 0
+	m.captures = nil
+	for i := range m.sources {
+		m.sources[i].Tail = nil
+	}
+	m.followOpen = false
+	m.cursorLine = 0
+	m.scrollOffset = 0
+	m.cancelModes()
+	m.clearSearch()
+	m.saveSession()
+	publish(eventCleared, nil)
+}
+
+// cancelModes closes the note input, visual selection and search prompt,
+// whose lines may be gone (e.g. after a clear over IPC)
+func (m *Model) cancelModes() {
+	m.noteInput.Reset()
+	m.noteInput.Blur()
+	m.inputMode = false
+	m.noteMatches = false
+	m.visualMode = false
+	m.visualAnchor = 0
+	m.searchInput = false
+	m.searchBuf = ""
+	m.searchOrigin = 0
+	if m.overlayType == overlayNote {
+		m.overlayType = overlayNone
+	}
+}
+
+// cursorHeld reports whether a note input, visual selection or search prompt
+// depends on the cursor, so new captures must not move it
+func (m Model) cursorHeld() bool {
+	return m.inputMode || m.visualMode || m.searchInput
+}
+
+// styledLine returns the line with its captured colors, or plain text if none
+func (m Model) styledLine(i int) string {
+	if i < len(m.styled) && m.styled[i] != "" {
+		return m.styled[i]
 	}
-	return CaptureAppendMsg(strings.TrimRight(string(out), "\n"))
+	return m.lines[i]
 }
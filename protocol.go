@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ipcProtocolVersion is the version of the IPC protocol spoken over the socket.
+//
+// Compatibility policy: the version only changes for incompatible changes
+// (a command or field removed, renamed or given a different meaning). New
+// commands, new optional request fields and new response fields are added
+// without a bump; clients find out what a server supports from the command
+// list in its hello reply. A server answers requests for its own version and
+// unversioned ("v" omitted) requests from clients that predate versioning,
+// and rejects newer versions with errUnsupportedVersion. A client refuses to
+// talk to a server speaking another version, so a plugin binary and an older
+// running TUI report the mismatch instead of misreading each other.
+const ipcProtocolVersion = 1
+
+// ipcMaxLine bounds one NDJSON line on the socket. Responses carry whole
+// captures (get-lines, export), so it is far above bufio's 64 KB default.
+const ipcMaxLine = 64 << 20
+
+// newLineScanner reads NDJSON lines of up to ipcMaxLine bytes
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), ipcMaxLine)
+	return scanner
+}
+
+// ipcCommands lists the commands handled by handleIPC and handleIPCConn,
+// advertised by hello
+var ipcCommands = []string{
+	"hello", "capture", "get-captures", "get-lines", "get-marks", "mark", "mark-pattern",
+	"unmark", "note", "clear-marks", "clear", "export", "subscribe", "batch",
+}
+
+// legacyIPCCommands are the commands of TUIs from before the versioned
+// protocol, which reject hello as an unknown command
+var legacyIPCCommands = []string{"capture", "get-marks", "mark", "export"}
+
+// IPC message types (controller -> TUI)
+type ipcRequest struct {
+	V       int             `json:"v,omitempty"`       // protocol version (omitted by pre-versioning clients)
+	ID      json.RawMessage `json:"id,omitempty"`      // echoed back in the response
+	Type    string          `json:"type"`              // command name (see ipcCommands)
+	Lines   ipcLines        `json:"lines,omitempty"`   // mark, unmark, note: 0-indexed lines; capture: line count
+	Mode    string          `json:"mode,omitempty"`    // capture mode (see captureModes)
+	Format  string          `json:"format,omitempty"`  // export format (defaults to the TUI's current one)
+	Pattern string          `json:"pattern,omitempty"` // regex for mark-pattern and get-lines
+	Note    string          `json:"note,omitempty"`    // note for note and mark-pattern
+	Events  []string        `json:"events,omitempty"`  // events for subscribe (empty = all)
+	Start   *int            `json:"start,omitempty"`   // get-lines: first line (0-indexed); capture: tmux line
+	End     *int            `json:"end,omitempty"`     // get-lines: last line, inclusive; capture: tmux line
+	Capture int             `json:"capture,omitempty"` // get-lines: only capture #N
+	Limit   int             `json:"limit,omitempty"`   // get-lines: max lines returned (0 = ipcLineLimit)
+
+	Requests []ipcRequest `json:"requests,omitempty"` // batch: requests run in one event loop turn
+}
+
+// ipcLines is a list of line numbers; a single number is accepted too, so
+// capture can take "lines": 50
+type ipcLines []int
+
+func (l *ipcLines) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*l = ipcLines{n}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]int)(l))
+}
+
+// IPC response types (TUI -> controller)
+type ipcResponse struct {
+	V       int             `json:"v,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Type    string          `json:"type"`           // "result" or "error"
+	Data    any             `json:"data,omitempty"` // one of the *Result types below
+	Message string          `json:"message,omitempty"`
+	Code    string          `json:"code,omitempty"` // error code (see errInvalidRequest...)
+}
+
+// error codes carried by error responses
+const (
+	errInvalidRequest     = "invalid-request"     // the line is not a JSON request
+	errUnsupportedVersion = "unsupported-version" // the request is for a newer protocol
+	errUnknownCommand     = "unknown-command"
+	errInvalidArgument    = "invalid-argument" // missing or out-of-range line, bad pattern, format or event
+	errNotReady           = "not-ready"        // the TUI event loop is not running
+	errTimeout            = "timeout"          // the TUI event loop did not answer in time
+	errExiting            = "tui-exiting"      // the TUI quit before answering
+	errFailed             = "failed"           // the command ran but failed (tmux, export)
+	errSkipped            = "skipped"          // not run because an earlier request in the batch failed
+)
+
+func ipcResult(data any) ipcResponse {
+	return ipcResponse{Type: "result", Data: data}
+}
+
+func ipcError(code, format string, args ...any) ipcResponse {
+	return ipcResponse{Type: "error", Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// helloResult describes the server, so clients can detect version mismatches
+type helloResult struct {
+	Version   string   `json:"version"`  // clipnote build version
+	Protocol  int      `json:"protocol"` // ipcProtocolVersion
+	Commands  []string `json:"commands"`
+	Events    []string `json:"events"`
+	Formats   []string `json:"formats"`
+	Pane      string   `json:"pane"`
+	SessionID string   `json:"session_id,omitempty"`
+	PID       int      `json:"pid"`
+}
+
+type captureResult struct {
+	Mode          string `json:"mode"`
+	Pane          string `json:"pane"`              // pane captured from: the source picked in the TUI
+	Capture       int    `json:"capture,omitempty"` // capture number (0 if nothing new was captured)
+	LinesCaptured int    `json:"lines_captured"`
+	TotalLines    int    `json:"total_lines"`
+}
+
+// skippedLine reports a requested line that a command left untouched
+type skippedLine struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+const (
+	skipOutOfRange    = "out of range"
+	skipAlreadyMarked = "already marked"
+	skipNotMarked     = "not marked"
+	skipHeader        = "capture header"
+)
+
+type markResult struct {
+	Marked  []int         `json:"marked"`
+	Skipped []skippedLine `json:"skipped"`
+}
+
+type unmarkResult struct {
+	Unmarked []int         `json:"unmarked"`
+	Skipped  []skippedLine `json:"skipped"`
+}
+
+type noteResult struct {
+	Mark    markData `json:"mark"`
+	Created bool     `json:"created"` // whether the line was marked by this command
+}
+
+type clearMarksResult struct {
+	Removed int `json:"removed"`
+}
+
+type clearResult struct {
+	LinesRemoved int `json:"lines_removed"`
+	MarksRemoved int `json:"marks_removed"`
+}
+
+type markPatternResult struct {
+	Marked  int `json:"marked"`
+	Noted   int `json:"noted"` // already marked lines whose mark had no note and got one
+	Matches int `json:"matches"`
+}
+
+// captureData is a capture block as returned by get-captures
+type captureData struct {
+	Capture int       `json:"capture"`
+	Pane    string    `json:"pane,omitempty"`
+	Label   string    `json:"label,omitempty"`
+	Time    time.Time `json:"time,omitzero"`
+	Mode    string    `json:"mode,omitempty"`
+	IPC     bool      `json:"ipc,omitempty"`
+	First   int       `json:"first"` // first captured line (0-indexed; the header is first-1)
+	Last    int       `json:"last"`  // last captured line
+}
+
+// lineData is one captured line as returned by get-lines
+type lineData struct {
+	Line    int    `json:"line"`
+	Text    string `json:"text"`
+	Capture int    `json:"capture,omitempty"`
+	Marked  bool   `json:"marked,omitempty"`
+}
+
+type linesResult struct {
+	Lines      []lineData `json:"lines"`
+	TotalLines int        `json:"total_lines"`
+	Next       *int       `json:"next,omitempty"` // start of the next page, when the limit cut the result
+}
+
+// ipcLineLimit is how many lines get-lines returns when no limit is given,
+// so a full scrollback is read page by page
+const ipcLineLimit = 500
+
+// markData is a mark as returned by get-marks and mark events
+type markData struct {
+	Line    int    `json:"line"`
+	End     int    `json:"end,omitempty"`
+	Text    string `json:"text"`
+	Note    string `json:"note,omitempty"`
+	Capture int    `json:"capture,omitempty"`
+	Pane    string `json:"pane,omitempty"` // pane the capture was taken from
+}
+
+func newMarkData(mk Mark) markData {
+	return markData{Line: mk.Line, End: mk.End, Text: mk.Text, Note: mk.Note, Capture: mk.Capture, Pane: mk.Pane}
+}
+
+type exportResult struct {
+	Exported string `json:"exported"`
+	Format   string `json:"format,omitempty"`
+	Status   string `json:"status,omitempty"`
+}
+
+// batchResult holds one response per batch request, in order
+type batchResult struct {
+	Responses []ipcResponse `json:"responses"`
+}
+
+type subscribeResult struct {
+	Subscribed []string `json:"subscribed"`
+}
+
+// capturedEvent is the payload of captured events
+type capturedEvent struct {
+	Capture    int    `json:"capture"`
+	Pane       string `json:"pane,omitempty"`
+	Lines      int    `json:"lines"`
+	TotalLines int    `json:"total_lines"`
+}
+
+// exportedEvent is the payload of exported events
+type exportedEvent struct {
+	Format string `json:"format"`
+	Target string `json:"target"` // "clipboard" or "pane"
+	Marks  int    `json:"marks"`
+	Text   string `json:"text"`
+}
+
+// overflowEvent is the payload of overflow events
+type overflowEvent struct {
+	Missed int `json:"missed"` // events dropped since the previous overflow event
+}
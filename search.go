@@ -0,0 +1,200 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// compileSearch compiles a search pattern as a regexp, falling back to a literal
+// match when it is not valid regexp syntax. Lowercase patterns match case-insensitively.
+func compileSearch(pattern string) *regexp.Regexp {
+	if pattern == "" {
+		return nil
+	}
+	prefix := ""
+	if !strings.ContainsFunc(pattern, unicode.IsUpper) {
+		prefix = "(?i)"
+	}
+	re, err := regexp.Compile(prefix + pattern)
+	if err != nil {
+		re = regexp.MustCompile(prefix + regexp.QuoteMeta(pattern))
+	}
+	return re
+}
+
+// setSearch makes pattern the active search and recomputes its matches
+func (m *Model) setSearch(pattern string) {
+	m.searchPattern = pattern
+	m.searchRe = compileSearch(pattern)
+	m.refreshSearch()
+}
+
+// refreshSearch recomputes matching lines (called whenever lines change)
+func (m *Model) refreshSearch() {
+	m.searchMatches = nil
+	if m.searchRe != nil {
+		m.searchMatches = m.matchingLines(m.searchRe)
+	}
+}
+
+// matchingLines returns the content lines matching re, skipping block headers
+func (m *Model) matchingLines(re *regexp.Regexp) []int {
+	var matches []int
+	for i, line := range m.lines {
+		if !m.isHeader(i) && re.MatchString(line) {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+// clearSearch turns off the active search and its highlighting
+func (m *Model) clearSearch() {
+	m.searchPattern = ""
+	m.searchRe = nil
+	m.searchMatches = nil
+}
+
+// nextMatch returns the first match after from (or before it when backward),
+// wrapping around the ends; inclusive includes from itself
+func (m *Model) nextMatch(from int, backward, inclusive bool) (line int, wrapped, ok bool) {
+	matches := m.searchMatches
+	if len(matches) == 0 {
+		return 0, false, false
+	}
+	if !backward {
+		i := sort.SearchInts(matches, from)
+		if i < len(matches) && matches[i] == from && !inclusive {
+			i++
+		}
+		if i == len(matches) {
+			return matches[0], true, true
+		}
+		return matches[i], false, true
+	}
+	i := sort.SearchInts(matches, from) // first index with match >= from
+	if i < len(matches) && matches[i] == from && inclusive {
+		return from, false, true
+	}
+	if i == 0 {
+		return matches[len(matches)-1], true, true
+	}
+	return matches[i-1], false, true
+}
+
+// jumpToMatch moves the cursor to the next match in the given direction
+func (m *Model) jumpToMatch(backward bool) {
+	if m.searchRe == nil {
+		m.statusMsg = "No active search"
+		return
+	}
+	line, wrapped, ok := m.nextMatch(m.cursorLine, backward, false)
+	if !ok {
+		m.statusMsg = "Pattern not found: " + m.searchPattern
+		return
+	}
+	m.unfoldAt(line)
+	m.cursorLine = line
+	m.syncViewport()
+	m.statusMsg = ""
+	if wrapped {
+		if backward {
+			m.statusMsg = "Search hit TOP, continuing at BOTTOM"
+		} else {
+			m.statusMsg = "Search hit BOTTOM, continuing at TOP"
+		}
+	}
+}
+
+// matchPosition returns the 1-based index of the cursor's line among matches (0 if not on one)
+func (m Model) matchPosition() int {
+	i := sort.SearchInts(m.searchMatches, m.cursorLine)
+	if i < len(m.searchMatches) && m.searchMatches[i] == m.cursorLine {
+		return i + 1
+	}
+	return 0
+}
+
+// startSearch opens the search prompt
+func (m Model) startSearch(backward bool) Model {
+	m.searchInput = true
+	m.searchBuf = ""
+	m.searchBackward = backward
+	m.searchOrigin = m.cursorLine
+	m.statusMsg = ""
+	return m
+}
+
+// handleSearchInput edits the search prompt, moving to the nearest match as you type
+func (m Model) handleSearchInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Escape):
+		m.searchInput = false
+		m.clearSearch()
+		m.cursorLine = m.searchOrigin
+		m.fixCursor()
+		m.syncViewport()
+		return m, nil
+
+	case key.Matches(msg, keys.Confirm):
+		m.searchInput = false
+		if m.searchBuf == "" {
+			m.clearSearch()
+			return m, nil
+		}
+		if len(m.searchMatches) == 0 {
+			m.statusMsg = "Pattern not found: " + m.searchPattern
+		}
+		return m, nil
+
+	case msg.Type == tea.KeyBackspace:
+		if m.searchBuf != "" {
+			runes := []rune(m.searchBuf)
+			m.searchBuf = string(runes[:len(runes)-1])
+		}
+
+	case msg.Type == tea.KeyCtrlU:
+		m.searchBuf = ""
+
+	case msg.Type == tea.KeySpace:
+		m.searchBuf += " "
+
+	case msg.Type == tea.KeyRunes:
+		m.searchBuf += string(msg.Runes)
+
+	default:
+		return m, nil
+	}
+
+	// incremental: re-run the search from where the prompt was opened
+	m.setSearch(m.searchBuf)
+	m.cursorLine = m.searchOrigin
+	if line, _, ok := m.nextMatch(m.searchOrigin, m.searchBackward, true); ok {
+		m.unfoldAt(line)
+		m.cursorLine = line
+	}
+	m.syncViewport()
+	return m, nil
+}
+
+// highlightMatches renders text with search matches highlighted over the base style
+func highlightMatches(text string, re *regexp.Regexp, base lipgloss.Style) string {
+	var sb strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		sb.WriteString(base.Render(text[last:loc[0]]))
+		sb.WriteString(searchStyle.Render(text[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	sb.WriteString(base.Render(text[last:]))
+	return sb.String()
+}
@@ -4,14 +4,13 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/mattn/go-isatty"
 )
 
-var knownCLIs = []string{"claude", "gemini", "codex", "aider"}
-
 const paneIDFile = "/tmp/clipnote-pane-id"
 
 func isInsideTmux() bool {
@@ -20,7 +19,7 @@ func isInsideTmux() bool {
 
 func detectCLIs() []string {
 	var found []string
-	for _, cli := range knownCLIs {
+	for _, cli := range cfg.CLIs {
 		if _, err := exec.LookPath(cli); err == nil {
 			found = append(found, cli)
 		}
@@ -45,7 +44,7 @@ func launchDetached(cli, sessionID string) error {
 	}
 
 	if isInsideTmux() {
-		return launchInTmuxSplit(self)
+		return launchInTmuxSplit(self, sessionID)
 	}
 
 	// create detached tmux session, left pane runs CLI (with resume support)
@@ -61,10 +60,35 @@ func currentPaneID() string {
 	return strings.TrimSpace(string(out))
 }
 
+// watchArgs returns the argv that runs the annotation TUI for a pane.
+// The session ID (if any) is passed along so the TUI restores the matching saved session.
+func watchArgs(self, paneID, sessionID string) []string {
+	args := []string{self, "--internal-watch", paneID}
+	if sessionID != "" {
+		args = append(args, "--session-id", sessionID)
+	}
+	return args
+}
+
+// shellQuote quotes s as a single sh word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellJoin quotes args into one sh command line, for the places where tmux
+// only takes a string (send-keys, run-shell)
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
 // launchInTmuxSplit opens the annotation TUI in a split pane within the current tmux window.
 // The TUI watches the caller's pane (Claude Code) directly — no new tmux session is created.
 // Reuses an existing pane if one is still alive.
-func launchInTmuxSplit(self string) error {
+func launchInTmuxSplit(self, sessionID string) error {
 	// get the pane ID of the caller (Claude Code) so the TUI can watch it
 	callerPane := currentPaneID()
 	if callerPane == "" {
@@ -72,16 +96,16 @@ func launchInTmuxSplit(self string) error {
 	}
 
 	// the split pane runs the annotation TUI directly, watching the caller's pane
-	watchCmd := fmt.Sprintf("%s --internal-watch %s", self, callerPane)
+	watch := watchArgs(self, callerPane, sessionID)
 
 	// try to reuse existing pane
-	if reused := tryReusePane(watchCmd); reused {
+	if reused := tryReusePane(shellJoin(watch)); reused {
 		return nil
 	}
 
-	// create new split pane (45% width), run annotation TUI directly
-	splitCmd := exec.Command("tmux", "split-window", "-h", "-l", "45%",
-		"-P", "-F", "#{pane_id}", watchCmd)
+	// create new split pane (config pane_split, default 45% width), run annotation TUI directly
+	splitCmd := exec.Command("tmux", append([]string{"split-window", "-h", "-l", cfg.PaneSplit,
+		"-P", "-F", "#{pane_id}"}, watch...)...)
 	out, err := splitCmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("tmux split-window failed: %w\n%s", err, out)
@@ -90,8 +114,7 @@ func launchInTmuxSplit(self string) error {
 	savePaneID(strings.TrimSpace(string(out)))
 
 	// bind prefix+a to toggle annotation pane
-	reopenCmd := fmt.Sprintf("%s --internal-watch %s", self, callerPane)
-	bindAnnotationKey(callerPane, reopenCmd)
+	bindAnnotationKey(callerPane, watch)
 
 	return nil
 }
@@ -107,7 +130,7 @@ func cliCommand(cli, sessionID string) string {
 
 // launchInCurrentTerminal creates a tmux session directly in the current terminal
 func launchInCurrentTerminal(cli, sessionID string) error {
-	sessionName := "clipnote"
+	sessionName := cfg.SessionName
 	self, err := os.Executable()
 	if err != nil {
 		return fmt.Errorf("failed to get executable path: %w", err)
@@ -119,16 +142,17 @@ func launchInCurrentTerminal(cli, sessionID string) error {
 	// create session, left pane runs the CLI (with resume if session ID provided)
 	leftCmd := cliCommand(cli, sessionID)
 	cmd := exec.Command("tmux",
-		"new-session", "-d", "-s", sessionName, "-x", "200", "-y", "50", leftCmd)
+		"new-session", "-d", "-s", sessionName,
+		"-x", strconv.Itoa(cfg.SessionWidth), "-y", strconv.Itoa(cfg.SessionHeight), leftCmd)
 	if out, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to create tmux session: %w\n%s", err, out)
 	}
 
-	// right pane launches annotation TUI (45% width)
-	splitCmd := exec.Command("tmux",
-		"split-window", "-h", "-t", sessionName, "-l", "45%",
-		"-P", "-F", "#{pane_id}",
-		self, "--internal-watch", sessionName+":0.0")
+	// right pane launches annotation TUI (config pane_split, default 45% width)
+	splitCmd := exec.Command("tmux", append([]string{
+		"split-window", "-h", "-t", sessionName, "-l", cfg.PaneSplit,
+		"-P", "-F", "#{pane_id}"},
+		watchArgs(self, sessionName+":0.0", sessionID)...)...)
 	out, err2 := splitCmd.CombinedOutput()
 	if err2 != nil {
 		exec.Command("tmux", "kill-session", "-t", sessionName).Run()
@@ -137,7 +161,7 @@ func launchInCurrentTerminal(cli, sessionID string) error {
 
 	savePaneID(strings.TrimSpace(string(out)))
 
-	configureTmuxSession(sessionName, self)
+	configureTmuxSession(sessionName, self, sessionID)
 
 	// attach to session (blocks until user detaches)
 	attachCmd := exec.Command("tmux", "attach-session", "-t", sessionName)
@@ -150,7 +174,7 @@ func launchInCurrentTerminal(cli, sessionID string) error {
 // launchNewTmuxAndAttach creates a detached tmux session and opens the user's terminal to attach.
 // Used when not inside tmux and no TTY (plugin subprocess).
 func launchNewTmuxAndAttach(cli, sessionID, self string) error {
-	sessionName := "clipnote"
+	sessionName := cfg.SessionName
 
 	// kill any leftover session with the same name
 	exec.Command("tmux", "kill-session", "-t", sessionName).Run()
@@ -158,16 +182,17 @@ func launchNewTmuxAndAttach(cli, sessionID, self string) error {
 	// create detached session, left pane runs CLI with resume
 	leftCmd := cliCommand(cli, sessionID)
 	cmd := exec.Command("tmux",
-		"new-session", "-d", "-s", sessionName, "-x", "200", "-y", "50", leftCmd)
+		"new-session", "-d", "-s", sessionName,
+		"-x", strconv.Itoa(cfg.SessionWidth), "-y", strconv.Itoa(cfg.SessionHeight), leftCmd)
 	if out, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to create tmux session: %w\n%s", err, out)
 	}
 
-	// right pane launches annotation TUI (45% width)
-	splitCmd := exec.Command("tmux",
-		"split-window", "-h", "-t", sessionName, "-l", "45%",
-		"-P", "-F", "#{pane_id}",
-		self, "--internal-watch", sessionName+":0.0")
+	// right pane launches annotation TUI (config pane_split, default 45% width)
+	splitCmd := exec.Command("tmux", append([]string{
+		"split-window", "-h", "-t", sessionName, "-l", cfg.PaneSplit,
+		"-P", "-F", "#{pane_id}"},
+		watchArgs(self, sessionName+":0.0", sessionID)...)...)
 	out, err2 := splitCmd.CombinedOutput()
 	if err2 != nil {
 		exec.Command("tmux", "kill-session", "-t", sessionName).Run()
@@ -176,7 +201,7 @@ func launchNewTmuxAndAttach(cli, sessionID, self string) error {
 
 	savePaneID(strings.TrimSpace(string(out)))
 
-	configureTmuxSession(sessionName, self)
+	configureTmuxSession(sessionName, self, sessionID)
 
 	// write a .command script and open it in the user's terminal
 	scriptPath := "/tmp/clipnote-attach.command"
@@ -222,32 +247,34 @@ func terminalAppName() string {
 }
 
 // configureTmuxSession sets mouse, border color, and bind-key for the session.
-func configureTmuxSession(sessionName, self string) {
+func configureTmuxSession(sessionName, self, sessionID string) {
 	// enable mouse support + unified pane border color
 	exec.Command("tmux", "set-option", "-t", sessionName, "mouse", "on").Run()
-	exec.Command("tmux", "set-option", "-t", sessionName, "pane-border-style", "fg=colour62").Run()
-	exec.Command("tmux", "set-option", "-t", sessionName, "pane-active-border-style", "fg=colour62").Run()
+	borderColor := "fg=" + tmuxColor(cfg.Colors.Accent)
+	exec.Command("tmux", "set-option", "-t", sessionName, "pane-border-style", borderColor).Run()
+	exec.Command("tmux", "set-option", "-t", sessionName, "pane-active-border-style", borderColor).Run()
 
 	// bind prefix+a to toggle annotation pane
-	reopenCmd := fmt.Sprintf("%s --internal-watch %s:0.0", self, sessionName)
-	bindAnnotationKey(sessionName+":0.0", reopenCmd)
+	bindAnnotationKey(sessionName+":0.0", watchArgs(self, sessionName+":0.0", sessionID))
 }
 
 // bindAnnotationKey binds prefix+a to toggle the annotation pane.
 // If the pane exists, it closes it; otherwise, it opens a new one.
-func bindAnnotationKey(targetPane, watchCmd string) {
+func bindAnnotationKey(targetPane string, watch []string) {
 	script := fmt.Sprintf(`
 pane_id=$(cat %s 2>/dev/null)
 if [ -n "$pane_id" ] && tmux display-message -t "$pane_id" -p "#{pane_id}" >/dev/null 2>&1; then
   tmux kill-pane -t "$pane_id"
   tmux display-message "Annotation pane closed"
 else
-  new_pane=$(tmux split-window -h -t %s -l 45%% -P -F "#{pane_id}" sh -c '%s')
+  new_pane=$(tmux split-window -h -t %s -l %s -P -F "#{pane_id}" %s)
   printf "%%s" "$new_pane" > %s
   tmux display-message "Annotation pane opened"
-fi`, paneIDFile, targetPane, watchCmd, paneIDFile)
+fi`, paneIDFile, shellQuote(targetPane), shellQuote(cfg.PaneSplit), shellJoin(watch), paneIDFile)
 
-	exec.Command("tmux", "bind-key", "a", "run-shell", script).Run()
+	// run-shell expands formats before sh sees the script; double the #s so
+	// the inner tmux commands get their own #{pane_id}
+	exec.Command("tmux", "bind-key", "a", "run-shell", strings.ReplaceAll(script, "#", "##")).Run()
 }
 
 // tryReusePane checks if a saved pane is still alive and sends a new command to it.
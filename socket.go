@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ipcInstance describes a running annotation TUI; it is written next to the
+// socket so clients can find the instance watching a given pane or session
+type ipcInstance struct {
+	Pane      string `json:"pane"`              // watched pane as passed to --internal-watch
+	PaneID    string `json:"pane_id,omitempty"` // the same pane as a tmux pane ID (e.g. %3)
+	SessionID string `json:"session_id,omitempty"`
+	PID       int    `json:"pid"`
+	Socket    string `json:"socket"`
+}
+
+// ipcDir returns the per-user socket directory ($XDG_RUNTIME_DIR/clipnote),
+// creating it with 0700 permissions
+func ipcDir() (string, error) {
+	dir := filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), "clipnote")
+	if os.Getenv("XDG_RUNTIME_DIR") == "" {
+		// /tmp rather than os.TempDir(): the macOS per-user temp dir is long
+		// enough to overflow the ~104 byte limit on socket paths
+		dir = filepath.Join("/tmp", fmt.Sprintf("clipnote-%d", os.Getuid()))
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("create socket dir: %w", err)
+	}
+
+	// the /tmp fallback is shared, so refuse a directory someone else planted
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return "", fmt.Errorf("socket dir: %w", err)
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); !fi.IsDir() || (ok && int(st.Uid) != os.Getuid()) {
+		return "", fmt.Errorf("socket dir %s is not a directory owned by the current user", dir)
+	}
+	if fi.Mode().Perm() != 0700 {
+		if err := os.Chmod(dir, 0700); err != nil {
+			return "", fmt.Errorf("socket dir: %w", err)
+		}
+	}
+	return dir, nil
+}
+
+// ipcSocketPath returns the socket for the instance watching paneID (or the
+// conversation session ID, when given), keyed like the session store
+func ipcSocketPath(paneID, sessionID string) (string, error) {
+	dir, err := ipcDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, sessionKey(paneID, sessionID)+".sock"), nil
+}
+
+// ipcInfoPath returns the instance info file that sits next to a socket
+func ipcInfoPath(socket string) string {
+	return strings.TrimSuffix(socket, ".sock") + ".json"
+}
+
+// socketAlive reports whether something is accepting connections on the socket
+func socketAlive(path string) bool {
+	conn, err := net.DialTimeout("unix", path, 500*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func writeInstanceInfo(info ipcInstance) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ipcInfoPath(info.Socket), data, 0600)
+}
+
+// listInstances returns the running annotation TUIs, removing files left
+// behind by instances that exited without cleaning up
+func listInstances() ([]ipcInstance, error) {
+	dir, err := ipcDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+
+	var instances []ipcInstance
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var info ipcInstance
+		if err := json.Unmarshal(data, &info); err != nil || info.Socket == "" {
+			continue
+		}
+		if !socketAlive(info.Socket) {
+			os.Remove(info.Socket)
+			os.Remove(path)
+			continue
+		}
+		instances = append(instances, info)
+	}
+	return instances, nil
+}
+
+// describeInstances formats instances for error messages, e.g. "%3 (session abc)"
+func describeInstances(instances []ipcInstance) string {
+	names := make([]string, len(instances))
+	for i, inst := range instances {
+		names[i] = inst.Pane
+		if inst.PaneID != "" && inst.PaneID != inst.Pane {
+			names[i] += " [" + inst.PaneID + "]"
+		}
+		if inst.SessionID != "" {
+			names[i] += " (session " + inst.SessionID + ")"
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// legacyIPCSocket is where TUIs from before per-instance sockets listen. They
+// write no instance file, so this path is the only way to find them.
+const legacyIPCSocket = "/tmp/clipnote.sock"
+
+// resolveSocket finds the socket of the instance to talk to: the one for the
+// given session or pane, or else the only running instance, or else the one
+// watching the caller's own pane ($TMUX_PANE). With no instance running it
+// falls back to a live legacy socket, so the handshake can report the old TUI.
+func resolveSocket(pane, sessionID string) (string, error) {
+	if sessionID != "" {
+		path, err := ipcSocketPath("", sessionID)
+		if err != nil {
+			return "", err
+		}
+		if !socketAlive(path) {
+			return "", fmt.Errorf("no clipnote instance for session %s", sessionID)
+		}
+		return path, nil
+	}
+
+	instances, err := listInstances()
+	if err != nil {
+		return "", err
+	}
+	matching := func(p string) []ipcInstance {
+		var found []ipcInstance
+		for _, inst := range instances {
+			if inst.Pane == p || inst.PaneID == p {
+				found = append(found, inst)
+			}
+		}
+		return found
+	}
+
+	if pane != "" {
+		found := matching(pane)
+		switch len(found) {
+		case 0:
+			return "", fmt.Errorf("no clipnote instance watching pane %s", pane)
+		case 1:
+			return found[0].Socket, nil
+		default:
+			return "", fmt.Errorf("several instances watch pane %s, pick one with --session: %s",
+				pane, describeInstances(found))
+		}
+	}
+
+	switch len(instances) {
+	case 0:
+		if socketAlive(legacyIPCSocket) {
+			return legacyIPCSocket, nil
+		}
+		return "", fmt.Errorf("no running clipnote instance (is annotation TUI running?)")
+	case 1:
+		return instances[0].Socket, nil
+	}
+	if own := os.Getenv("TMUX_PANE"); own != "" {
+		if found := matching(own); len(found) == 1 {
+			return found[0].Socket, nil
+		}
+	}
+	return "", fmt.Errorf("%d clipnote instances running, pick one with --pane or --session: %s",
+		len(instances), describeInstances(instances))
+}
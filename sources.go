@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// captureSource is a pane the user captures from. The watched pane is always
+// the first one; others are added with the pane picker.
+type captureSource struct {
+	Pane  string    `json:"pane"`            // tmux pane ID (or the --internal-watch target)
+	Label string    `json:"label,omitempty"` // e.g. "main:1.2 pytest", shown in separators
+	Tail  *paneTail `json:"tail,omitempty"`  // where the previous capture from this pane ended
+}
+
+// paneInfo is one row of the pane picker
+type paneInfo struct {
+	ID      string // %N
+	Target  string // session:window.pane
+	Command string
+	Title   string
+}
+
+// label describes the pane for separators and the status bar
+func (p paneInfo) label() string {
+	return strings.TrimSpace(p.Target + " " + p.Command)
+}
+
+// panesMsg carries the result of listPanes
+type panesMsg struct {
+	panes []paneInfo
+	err   error
+}
+
+// listPanes lists every tmux pane except the annotation TUI's own
+func listPanes() tea.Cmd {
+	return func() tea.Msg {
+		out, err := execCommand("tmux", "list-panes", "-a", "-F",
+			"#{pane_id}\t#{session_name}:#{window_index}.#{pane_index}\t#{pane_current_command}\t#{pane_title}").CombinedOutput()
+		if err != nil {
+			return panesMsg{err: fmt.Errorf("%s", strings.TrimSpace(string(out)))}
+		}
+		self := os.Getenv("TMUX_PANE")
+		var panes []paneInfo
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			f := strings.SplitN(line, "\t", 4)
+			if len(f) < 4 || f[0] == self {
+				continue
+			}
+			panes = append(panes, paneInfo{ID: f[0], Target: f[1], Command: f[2], Title: f[3]})
+		}
+		return panesMsg{panes: panes}
+	}
+}
+
+// sourcePane returns the pane that captures currently read from
+func (m Model) sourcePane() string {
+	if m.source < len(m.sources) {
+		return m.sources[m.source].Pane
+	}
+	return m.watchedPane()
+}
+
+// watchedPane returns the watched pane as recorded on its captures: its pane
+// ID (%N) when tmux could resolve it
+func (m Model) watchedPane() string {
+	return m.sources[0].Pane
+}
+
+// sourceTail returns where the previous capture from the current source ended
+func (m Model) sourceTail() *paneTail {
+	if m.source < len(m.sources) {
+		return m.sources[m.source].Tail
+	}
+	return nil
+}
+
+// sourceIndex returns the index of pane in sources, adding it if needed
+func (m *Model) sourceIndex(pane string) int {
+	i := slices.IndexFunc(m.sources, func(s captureSource) bool { return s.Pane == pane })
+	if i < 0 {
+		m.sources = append(m.sources, captureSource{Pane: pane})
+		i = len(m.sources) - 1
+	}
+	return i
+}
+
+// setTail records where the latest capture from pane ended (nil = keep previous)
+func (m *Model) setTail(pane string, tail *paneTail) {
+	if tail != nil {
+		m.sources[m.sourceIndex(pane)].Tail = tail
+	}
+}
+
+// paneLabel describes a capture source pane, e.g. "main:1.2 pytest"
+func (m Model) paneLabel(pane string) string {
+	for _, s := range m.sources {
+		if s.Pane == pane && s.Label != "" {
+			return s.Label
+		}
+	}
+	return pane
+}
+
+// handlePanes opens the pane picker with the listed panes
+func (m Model) handlePanes(msg panesMsg) Model {
+	if msg.err != nil {
+		m.statusMsg = "Cannot list panes: " + msg.err.Error()
+		return m
+	}
+	if len(msg.panes) == 0 {
+		m.statusMsg = "No other panes"
+		return m
+	}
+	m.pickerPanes = msg.panes
+	m.pickerCursor = max(slices.IndexFunc(msg.panes, func(p paneInfo) bool { return p.ID == m.sourcePane() }), 0)
+	m.overlayType = overlayPanes
+	return m
+}
+
+// handlePanePicker moves through the pane picker; Enter makes the selected
+// pane the capture source (adding it to the sources)
+func (m Model) handlePanePicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Down):
+		m.pickerCursor = min(m.pickerCursor+1, len(m.pickerPanes)-1)
+	case key.Matches(msg, keys.Up):
+		m.pickerCursor = max(m.pickerCursor-1, 0)
+	case key.Matches(msg, keys.Confirm):
+		m.overlayType = overlayNone
+		return m.switchSource(m.pickerPanes[m.pickerCursor])
+	default:
+		m.overlayType = overlayNone
+	}
+	return m, nil
+}
+
+// switchSource makes p the pane that captures read from. Follow mode moves
+// along to the new pane.
+func (m Model) switchSource(p paneInfo) (Model, tea.Cmd) {
+	m.source = m.sourceIndex(p.ID)
+	m.sources[m.source].Label = p.label()
+	m.statusMsg = "Capturing from " + p.ID + " (" + p.label() + ")"
+	m.saveSession()
+	if !m.following {
+		return m, nil
+	}
+	m.followGen++
+	m.followOpen = false
+	return m, captureFollow(m.sourcePane(), m.sourceTail(), m.followGen)
+}
+
+// pickerContent renders the pane picker overlay
+func (m Model) pickerContent() string {
+	lines := []string{"Capture source", ""}
+	for i, p := range m.pickerPanes {
+		cursor := "  "
+		if i == m.pickerCursor {
+			cursor = "> "
+		}
+		state := " "
+		switch {
+		case p.ID == m.sourcePane():
+			state = "●"
+		case slices.ContainsFunc(m.sources, func(s captureSource) bool { return s.Pane == p.ID }):
+			state = "+"
+		}
+		row := fmt.Sprintf("%s%s %-4s %-12s %-10s %s", cursor, state, p.ID, p.Target, p.Command, p.Title)
+		lines = append(lines, strings.TrimRight(truncateLine(row, m.width-10), " "))
+	}
+	lines = append(lines, "",
+		"● current source  + used before",
+		fmt.Sprintf("%s/%s move | %s capture from pane | any other key to close", firstKey(keys.Down), firstKey(keys.Up), firstKey(keys.Confirm)))
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sessionState is the on-disk snapshot of an annotation session
+type sessionState struct {
+	Lines      []string        `json:"lines"`
+	Styled     []string        `json:"styled,omitempty"`
+	Marks      []Mark          `json:"marks"`
+	Captures   []captureBlock  `json:"captures,omitempty"`
+	Sources    []captureSource `json:"sources,omitempty"`
+	Source     int             `json:"source,omitempty"`
+	CursorLine int             `json:"cursor_line"`
+}
+
+// stateDir returns the directory for persisted sessions ($XDG_STATE_HOME/clipnote)
+func stateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "clipnote")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "clipnote-state")
+	}
+	return filepath.Join(home, ".local", "state", "clipnote")
+}
+
+// sessionKey picks the store key: the conversation session ID if known,
+// otherwise the watched pane ID
+func sessionKey(paneID, sessionID string) string {
+	key := sessionID
+	if key == "" {
+		key = "pane-" + paneID
+	}
+	return sanitizeKey(key)
+}
+
+// sanitizeKey maps a session/pane identifier to a safe file name
+func sanitizeKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
+
+// storePaneKey identifies the watched pane for the store when there is no
+// session ID. A pane target such as clipnote:0.0 names a new pane on every
+// launch and tmux reuses %N IDs after a restart, so the key pairs the pane ID
+// with its session's creation time to stay unique to the pane's lifetime.
+func storePaneKey(paneID string) string {
+	id := tmuxDisplayVar(paneID, "pane_id")
+	created := tmuxDisplayVar(paneID, "session_created")
+	if id == "" || created == "" {
+		return paneID
+	}
+	return id + "-" + created
+}
+
+func sessionStorePath(paneID, sessionID string) string {
+	return filepath.Join(stateDir(), sessionKey(paneID, sessionID)+".json")
+}
+
+// loadSession restores lines and marks from the store, if a snapshot exists
+func (m *Model) loadSession() {
+	if m.storePath == "" {
+		return
+	}
+	data, err := os.ReadFile(m.storePath)
+	if err != nil {
+		return
+	}
+	var st sessionState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return
+	}
+
+	m.lines = st.Lines
+	if m.lines == nil {
+		m.lines = []string{}
+	}
+	if len(st.Styled) == len(m.lines) {
+		m.styled = st.Styled
+	}
+	m.marks = []Mark{}
+	for _, mk := range st.Marks {
+		if mk.Line >= 0 && mk.Last() < len(m.lines) {
+			m.marks = append(m.marks, mk)
+		}
+	}
+	m.captures = st.Captures
+	m.captureCount = len(m.captures)
+	// picked sources and tails only apply while watching the same pane
+	if len(st.Sources) > 0 && st.Sources[0].Pane == m.watchedPane() {
+		m.sources = st.Sources
+		if st.Source < len(m.sources) {
+			m.source = st.Source
+		}
+	}
+	m.cursorLine = st.CursorLine
+	m.fixCursor()
+	if len(m.lines) > 0 {
+		m.statusMsg = "Restored " + itoa(len(m.lines)) + " lines, " + itoa(len(m.marks)) + " marks"
+	}
+}
+
+// saveSession writes the current lines and marks to the store.
+// Written to a temp file first so a crash never leaves a truncated snapshot.
+func (m *Model) saveSession() {
+	if m.storePath == "" {
+		return
+	}
+	st := sessionState{
+		Lines:      m.lines,
+		Styled:     m.styled,
+		Marks:      m.marks,
+		Captures:   m.captures,
+		Sources:    m.sources,
+		Source:     m.source,
+		CursorLine: m.cursorLine,
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(m.storePath), 0700); err != nil {
+		return
+	}
+	tmp := m.storePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return
+	}
+	os.Rename(tmp, m.storePath)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateContextLines is the number of surrounding lines exposed to templates
+const templateContextLines = 3
+
+// templateDir holds user export templates (<name>.tmpl becomes format <name>)
+func templateDir() string {
+	dir := configDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "templates")
+}
+
+// templateMark is the per-mark data available to export templates
+type templateMark struct {
+	Line    int      // first line, 1-indexed
+	End     int      // last line, 1-indexed (same as Line for single-line marks)
+	Label   string   // "L3" or "L3-10"
+	Text    string   // full marked text
+	Lines   []string // marked text split into lines
+	Note    string
+	Capture int       // capture number (#N) the line came from
+	Pane    string    // pane that capture was taken from
+	Time    time.Time // when that capture was taken (zero if unknown)
+	Mode    string    // its capture mode (visible, lines, range, all, new, follow)
+	Source  string    // pane, time and mode in one line, e.g. "%4 main:1.2 pytest, 14:03:27, visible"
+	Before  []string  // context lines above the mark, within the same capture
+	After   []string  // context lines below the mark, within the same capture
+}
+
+// templateData is the root object passed to export templates
+type templateData struct {
+	Marks []templateMark
+	Count int
+}
+
+var templateFuncs = template.FuncMap{
+	"truncate": func(n int, s string) string {
+		runes := []rune(s)
+		if len(runes) <= n {
+			return s
+		}
+		return string(runes[:n]) + "..."
+	},
+	"indent": func(prefix, s string) string {
+		return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+	},
+	"join":  func(sep string, s []string) string { return strings.Join(s, sep) },
+	"lines": func(s string) []string { return strings.Split(s, "\n") },
+	"trim":  strings.TrimSpace,
+}
+
+// templateExporter renders marks through a user-provided text/template
+type templateExporter struct {
+	name string
+	tmpl *template.Template
+}
+
+func (t templateExporter) Name() string { return t.name }
+
+func (t templateExporter) Export(marks []Mark, lines []string, blocks []captureBlock) (string, error) {
+	data := templateData{Count: len(marks)}
+	for _, mk := range marks {
+		before, after := contextLines(lines, blocks, mk, templateContextLines)
+		tm := templateMark{
+			Line:    mk.Line + 1,
+			End:     mk.Last() + 1,
+			Label:   mk.Label(),
+			Text:    mk.Text,
+			Lines:   strings.Split(mk.Text, "\n"),
+			Note:    mk.Note,
+			Capture: mk.Capture,
+			Pane:    mk.Pane,
+			Before:  before,
+			After:   after,
+		}
+		if b := blockOf(blocks, mk); b != nil {
+			tm.Time, tm.Mode, tm.Source = b.Time, b.Mode, b.source()
+		}
+		data.Marks = append(data.Marks, tm)
+	}
+
+	var sb strings.Builder
+	if err := t.tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// contextLines returns up to n lines around the mark, within its capture block
+func contextLines(lines []string, blocks []captureBlock, mk Mark, n int) (before, after []string) {
+	first, last := 0, len(lines)-1
+	if i := blockIndexIn(blocks, mk.Line); i >= 0 {
+		first, last = blockBoundsIn(blocks, i, len(lines))
+	}
+	for i := mk.Line - 1; i >= first && i >= mk.Line-n; i-- {
+		before = append([]string{lines[i]}, before...)
+	}
+	for i := mk.Last() + 1; i <= last && i <= mk.Last()+n; i++ {
+		after = append(after, lines[i])
+	}
+	return before, after
+}
+
+// loadTemplateExporters registers every *.tmpl file in the template directory
+// as an export format. A template named like a built-in format replaces it.
+func loadTemplateExporters() error {
+	dir := templateDir()
+	if dir == "" {
+		return nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil || len(paths) == 0 {
+		return nil
+	}
+	sort.Strings(paths)
+
+	var failed []string
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+		if err != nil {
+			failed = append(failed, name)
+			continue
+		}
+		registerExporter(templateExporter{name: name, tmpl: tmpl})
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("invalid export templates: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// registerExporter adds an exporter, replacing any existing one with the same name
+func registerExporter(e Exporter) {
+	for i, existing := range exporters {
+		if existing.Name() == e.Name() {
+			exporters[i] = e
+			return
+		}
+	}
+	exporters = append(exporters, e)
+}
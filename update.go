@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
 )
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -22,14 +24,33 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case IPCMsg:
+		if !msg.start() {
+			return m, nil // already answered with a timeout
+		}
 		resp := m.handleIPC(msg.Request)
 		msg.ReplyCh <- resp
 		return m, nil
 
+	case ipcServerErrMsg:
+		m.statusMsg = "IPC disabled: " + msg.err.Error()
+		return m, nil
+
 	case CaptureAppendMsg:
-		return m.handleCaptureAppend(string(msg)), nil
+		if msg.FollowGen != 0 {
+			return m.handleFollowCapture(msg)
+		}
+		return m.handleCaptureAppend(msg), nil
+
+	case panesMsg:
+		return m.handlePanes(msg), nil
+
+	case followTickMsg:
+		return m.handleFollowTick(msg)
 
 	case tea.KeyMsg:
+		if m.overlayType == overlayPanes {
+			return m.handlePanePicker(msg)
+		}
 		if m.overlayType != overlayNone {
 			m.overlayType = overlayNone
 			return m, nil
@@ -43,10 +64,18 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.handleCaptureInput(msg)
 		}
 
+		if m.searchInput {
+			return m.handleSearchInput(msg)
+		}
+
 		if m.inputMode {
 			return m.handleInputMode(msg)
 		}
 
+		if m.visualMode {
+			return m.handleVisualMode(msg)
+		}
+
 		return m.handleBrowseMode(msg)
 	}
 
@@ -54,38 +83,79 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 // handleCaptureAppend appends captured content to existing lines
-func (m Model) handleCaptureAppend(content string) Model {
-	m.captureCount++
-	if len(m.lines) > 0 {
-		separator := fmt.Sprintf("─── Capture #%d ───", m.captureCount)
-		m.lines = append(m.lines, separator)
+func (m Model) handleCaptureAppend(msg CaptureAppendMsg) Model {
+	m.followOpen = false
+	m.setTail(msg.Pane, msg.Tail)
+	if msg.Incremental && msg.Content == "" {
+		m.statusMsg = "No new output since last capture"
+		m.saveSession()
+		return m
+	}
+
+	block := captureBlock{
+		Start: len(m.lines),
+		Pane:  msg.Pane,
+		Time:  time.Now(),
+		Mode:  msg.Mode,
+		IPC:   msg.IPC,
+	}
+	if msg.Pane != m.watchedPane() {
+		block.Label = m.paneLabel(msg.Pane)
+	}
+	if msg.FollowGen != 0 {
+		block.Mode = captureModeFollow
 	}
-	newLines := strings.Split(content, "\n")
+	m.captureCount++
+	m.captures = append(m.captures, block)
+	m.appendLines([]string{block.headerText(m.captureCount)}, nil)
+
+	newLines := strings.Split(msg.Content, "\n")
 	jumpTo := len(m.lines) // start position of newly captured content
-	m.lines = append(m.lines, newLines...)
-	m.cursorLine = jumpTo
-	m.syncViewport()
+	m.appendLines(newLines, msg.Styled)
+	if !m.cursorHeld() {
+		m.cursorLine = jumpTo
+		m.syncViewport()
+	}
 	m.statusMsg = "Captured " + itoa(len(newLines)) + " lines (total " + itoa(len(m.lines)) + ")"
+	m.saveSession()
+	m.publishCaptured(len(newLines))
 	return m
 }
 
+// publishCaptured notifies subscribers that n lines were added to the latest capture
+func (m *Model) publishCaptured(n int) {
+	var pane string
+	if len(m.captures) > 0 {
+		pane = m.captures[len(m.captures)-1].Pane
+	}
+	publish(eventCaptured, capturedEvent{Capture: m.captureCount, Pane: pane, Lines: n, TotalLines: len(m.lines)})
+}
+
 func (m Model) handleInputMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch {
 	case key.Matches(msg, keys.SubmitNote):
 		note := m.noteInput.Value()
-		if note != "" {
+		m.statusMsg = ""
+		if m.noteMatches {
+			n, noted := m.MarkLines(m.searchMatches, note)
+			m.statusMsg = fmt.Sprintf("Marked %d of %d matches", n, len(m.searchMatches))
+			if noted > 0 {
+				m.statusMsg += fmt.Sprintf(", noted %d already marked", noted)
+			}
+		} else if note != "" {
 			m.AddMarkWithNote(m.cursorLine, note)
 		}
 		m.noteInput.Reset()
 		m.noteInput.Blur()
 		m.inputMode = false
-		m.statusMsg = ""
+		m.noteMatches = false
 		return m, nil
 
 	case key.Matches(msg, keys.Escape):
 		m.noteInput.Reset()
 		m.noteInput.Blur()
 		m.inputMode = false
+		m.noteMatches = false
 		m.statusMsg = ""
 		return m, nil
 
@@ -96,16 +166,81 @@ func (m Model) handleInputMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	}
 }
 
+// visualRange returns the current visual selection as (start, end).
+// The selection stays within the anchor's capture block.
+func (m Model) visualRange() (int, int) {
+	if m.visualAnchor <= m.cursorLine {
+		return m.visualAnchor, m.cursorLine
+	}
+	return m.cursorLine, m.visualAnchor
+}
+
+// handleVisualMode handles V line selection: move to extend, m to mark, c to mark+note
+func (m Model) handleVisualMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// the content may have changed under the selection over IPC
+	if m.visualAnchor >= len(m.lines) || !m.markable(m.visualAnchor) {
+		m.visualMode = false
+		m.statusMsg = "Selection cancelled: content changed"
+		return m, nil
+	}
+	first, last := m.blockSpan(blockIndexIn(m.captures, m.visualAnchor))
+	m.cursorLine = max(min(m.cursorLine, last), first)
+
+	switch {
+	case key.Matches(msg, keys.Escape), key.Matches(msg, keys.Visual):
+		m.visualMode = false
+		m.statusMsg = ""
+
+	case key.Matches(msg, keys.Down):
+		m.cursorLine = min(m.cursorLine+1, last)
+		m.syncViewport()
+
+	case key.Matches(msg, keys.Up):
+		m.cursorLine = max(m.cursorLine-1, first)
+		m.syncViewport()
+
+	case key.Matches(msg, keys.Top):
+		m.cursorLine = first
+		m.syncViewport()
+
+	case key.Matches(msg, keys.Bottom):
+		m.cursorLine = last
+		m.syncViewport()
+
+	case key.Matches(msg, keys.Mark):
+		start, end := m.visualRange()
+		m.visualMode = false
+		if !m.AddRangeMark(start, end) {
+			m.statusMsg = "Cannot mark that selection"
+			break
+		}
+		m.statusMsg = "Marked " + m.GetMark(start).Label()
+
+	case key.Matches(msg, keys.Comment):
+		start, end := m.visualRange()
+		m.visualMode = false
+		if !m.AddRangeMark(start, end) {
+			m.statusMsg = "Cannot mark that selection"
+			break
+		}
+		m.cursorLine = start
+		m.syncViewport()
+		m.inputMode = true
+		return m, m.noteInput.Focus()
+	}
+	return m, nil
+}
+
 // handleCaptureInput handles R key line count input mode
 func (m Model) handleCaptureInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.Type {
-	case tea.KeyEscape:
+	switch {
+	case key.Matches(msg, keys.Escape):
 		m.captureInput = false
 		m.captureInputBuf = ""
 		m.statusMsg = ""
 		return m, nil
 
-	case tea.KeyEnter:
+	case key.Matches(msg, keys.Confirm):
 		m.captureInput = false
 		input := strings.TrimSpace(m.captureInputBuf)
 		m.captureInputBuf = ""
@@ -122,9 +257,9 @@ func (m Model) handleCaptureInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.statusMsg = "Please enter a positive integer"
 			return m, nil
 		}
-		return m, captureRange(m.tmuxPane, n)
+		return m, captureRange(m.sourcePane(), n)
 
-	case tea.KeyBackspace:
+	case msg.Type == tea.KeyBackspace:
 		if len(m.captureInputBuf) > 0 {
 			m.captureInputBuf = m.captureInputBuf[:len(m.captureInputBuf)-1]
 		}
@@ -147,7 +282,7 @@ func (m Model) handleCaptureConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "y", "Y":
 		m.statusMsg = "Capturing full scrollback..."
-		return m, captureAll(m.tmuxPane)
+		return m, captureAll(m.sourcePane())
 	default:
 		m.statusMsg = "Cancelled"
 		return m, nil
@@ -160,14 +295,29 @@ func (m Model) handleBrowseMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, tea.Quit
 
 	case key.Matches(msg, keys.ClearAll):
-		m.lines = []string{}
-		m.marks = []Mark{}
-		m.captureCount = 0
-		m.cursorLine = 0
+		m.clearAll()
 		m.statusMsg = "Cleared all content and marks"
 
+	case key.Matches(msg, keys.DeleteBlock):
+		i := blockIndexIn(m.captures, m.cursorLine)
+		if i < 0 {
+			break
+		}
+		marks := len(m.marks)
+		m.deleteBlock(i)
+		m.statusMsg = fmt.Sprintf("Deleted capture #%d (%d marks removed)", i+1, marks-len(m.marks))
+
 	case key.Matches(msg, keys.Capture):
-		return m, captureVisible(m.tmuxPane)
+		return m, captureVisible(m.sourcePane())
+
+	case key.Matches(msg, keys.CaptureNew):
+		return m, captureNew(m.sourcePane(), m.sourceTail())
+
+	case key.Matches(msg, keys.PanePicker):
+		return m, listPanes()
+
+	case key.Matches(msg, keys.Follow):
+		return m.toggleFollow()
 
 	case key.Matches(msg, keys.CaptureRange):
 		m.captureInput = true
@@ -176,42 +326,67 @@ func (m Model) handleBrowseMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case key.Matches(msg, keys.Down):
-		if m.cursorLine < len(m.lines)-1 {
-			m.cursorLine++
-			m.syncViewport()
-		}
+		m.moveCursor(1)
+		m.syncViewport()
 		m.statusMsg = ""
 
 	case key.Matches(msg, keys.Up):
-		if m.cursorLine > 0 {
-			m.cursorLine--
-			m.syncViewport()
-		}
+		m.moveCursor(-1)
+		m.syncViewport()
 		m.statusMsg = ""
 
 	case key.Matches(msg, keys.Top):
 		m.cursorLine = 0
+		m.fixCursor()
 		m.scrollOffset = 0
 		m.statusMsg = ""
 
 	case key.Matches(msg, keys.Bottom):
 		m.cursorLine = len(m.lines) - 1
+		m.fixCursor()
 		m.syncViewport()
 		m.statusMsg = ""
 
+	case key.Matches(msg, keys.NextBlock):
+		m.jumpBlock(1)
+
+	case key.Matches(msg, keys.PrevBlock):
+		m.jumpBlock(-1)
+
+	case key.Matches(msg, keys.Fold):
+		m.toggleFold()
+		m.statusMsg = ""
+
+	case key.Matches(msg, keys.FoldAll):
+		m.toggleFoldAll()
+
+	case key.Matches(msg, keys.Search):
+		return m.startSearch(false), nil
+
+	case key.Matches(msg, keys.SearchBack):
+		return m.startSearch(true), nil
+
+	case key.Matches(msg, keys.SearchNext):
+		m.jumpToMatch(m.searchBackward)
+
+	case key.Matches(msg, keys.SearchPrev):
+		m.jumpToMatch(!m.searchBackward)
+
 	case key.Matches(msg, keys.Mark):
-		if len(m.lines) == 0 {
+		if len(m.lines) == 0 || m.isHeader(m.cursorLine) {
 			break
 		}
-		m.ToggleMark(m.cursorLine)
-		if m.HasMark(m.cursorLine) {
-			m.statusMsg = "Marked L" + itoa(m.cursorLine+1)
+		if mk := m.GetMark(m.cursorLine); mk != nil {
+			label := mk.Label()
+			m.RemoveMark(m.cursorLine)
+			m.statusMsg = "Unmarked " + label
 		} else {
-			m.statusMsg = "Unmarked L" + itoa(m.cursorLine+1)
+			m.ToggleMark(m.cursorLine)
+			m.statusMsg = "Marked L" + itoa(m.cursorLine+1)
 		}
 
 	case key.Matches(msg, keys.Comment):
-		if len(m.lines) == 0 {
+		if len(m.lines) == 0 || m.isHeader(m.cursorLine) {
 			break
 		}
 		if !m.HasMark(m.cursorLine) {
@@ -221,20 +396,46 @@ func (m Model) handleBrowseMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.noteInput.Focus()
 		return m, m.noteInput.Focus()
 
+	case key.Matches(msg, keys.MarkMatches):
+		if len(m.searchMatches) == 0 {
+			m.statusMsg = "No search matches to mark"
+			break
+		}
+		n, _ := m.MarkLines(m.searchMatches, "")
+		m.statusMsg = fmt.Sprintf("Marked %d of %d matches", n, len(m.searchMatches))
+
+	case key.Matches(msg, keys.NoteMatches):
+		if len(m.searchMatches) == 0 {
+			m.statusMsg = "No search matches to mark"
+			break
+		}
+		m.noteMatches = true
+		m.inputMode = true
+		return m, m.noteInput.Focus()
+
 	case key.Matches(msg, keys.Submit):
 		m.statusMsg = m.CopyMarksToClipboard()
 
 	case key.Matches(msg, keys.PasteToPane):
 		m.statusMsg = m.PasteMarksToPane()
 
+	case key.Matches(msg, keys.ExportFormat):
+		m.statusMsg = "Export format: " + m.cycleExportFormat()
+
 	case key.Matches(msg, keys.ViewNote):
-		for _, mk := range m.marks {
-			if mk.Line == m.cursorLine && mk.Note != "" {
-				m.overlayType = overlayNote
-				return m, nil
-			}
+		if mk := m.GetMark(m.cursorLine); mk != nil && mk.Note != "" {
+			m.overlayType = overlayNote
+			return m, nil
 		}
 
+	case key.Matches(msg, keys.Visual):
+		if len(m.lines) == 0 || m.isHeader(m.cursorLine) {
+			break
+		}
+		m.visualMode = true
+		m.visualAnchor = m.cursorLine
+		m.statusMsg = ""
+
 	case key.Matches(msg, keys.Help):
 		m.overlayType = overlayHelp
 
@@ -247,6 +448,21 @@ func (m Model) handleBrowseMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if m.splitRatio < 90 {
 			m.splitRatio += 5
 		}
+
+	case key.Matches(msg, keys.Wrap):
+		m.wrap = !m.wrap
+		m.hscroll = 0
+		m.syncViewport()
+		m.statusMsg = "Wrap off"
+		if m.wrap {
+			m.statusMsg = "Wrap on"
+		}
+
+	case key.Matches(msg, keys.ScrollRight):
+		m.scrollHorizontal(hscrollStep)
+
+	case key.Matches(msg, keys.ScrollLeft):
+		m.scrollHorizontal(-hscrollStep)
 	}
 
 	return m, nil
@@ -259,15 +475,78 @@ func (m *Model) syncViewport() {
 		return
 	}
 
-	// cursor above visible area
-	if m.cursorLine < m.scrollOffset {
-		m.scrollOffset = m.cursorLine
+	// cursor above visible area (keeping the header of its block in view
+	// when the cursor is on the block's first line)
+	top := m.cursorLine
+	if top > 0 && m.isHeader(top-1) {
+		top--
+	}
+	if top < m.scrollOffset {
+		m.scrollOffset = top
 	}
 
 	// cursor below visible area
-	if m.cursorLine >= m.scrollOffset+contentHeight {
-		m.scrollOffset = m.cursorLine - contentHeight + 1
+	m.scrollOffset = m.viewTop(contentHeight)
+}
+
+// viewTop returns the first line shown in a content view of height rows:
+// scrollOffset, moved just enough to keep the cursor line in view. Folded
+// blocks take one row, wrapped lines as many as they need.
+func (m Model) viewTop(height int) int {
+	top := m.shownAt(max(min(m.scrollOffset, len(m.lines)-1), 0))
+	if top > m.cursorLine {
+		return m.cursorLine
+	}
+	rows := 0
+	for i := top; i < m.cursorLine && rows < height; i = m.nextShown(i) {
+		rows += m.lineRows(i)
+	}
+	if rows+m.lineRows(m.cursorLine) <= height {
+		return top
+	}
+	// walk back from the cursor to fill the view
+	top = m.cursorLine
+	rows = m.lineRows(top)
+	for {
+		prev := m.prevShown(top)
+		if prev < 0 || rows+m.lineRows(prev) > height {
+			return top
+		}
+		rows += m.lineRows(prev)
+		top = prev
+	}
+}
+
+// contentWidth returns the width of the content panel
+func (m Model) contentWidth() int {
+	return m.width*m.splitRatio/100 - 2
+}
+
+// lineRows returns the number of screen rows line takes in the content panel
+func (m Model) lineRows(line int) int {
+	textWidth := m.contentWidth() - 8
+	if !m.wrap || textWidth <= 0 || m.isHeader(line) {
+		return 1
+	}
+	return max(1, (ansi.StringWidth(m.lines[line])+textWidth-1)/textWidth)
+}
+
+// hscrollStep is how many cells the horizontal scroll keys move
+const hscrollStep = 8
+
+// scrollHorizontal shifts the content panel by delta cells, up to where the
+// longest line ends
+func (m *Model) scrollHorizontal(delta int) {
+	if m.wrap {
+		m.statusMsg = "No horizontal scrolling while wrapping (" + firstKey(keys.Wrap) + " to unwrap)"
+		return
+	}
+	widest := 0
+	for _, line := range m.lines {
+		widest = max(widest, ansi.StringWidth(line))
 	}
+	m.hscroll = max(min(m.hscroll+delta, widest-(m.contentWidth()-8)), 0)
+	m.statusMsg = ""
 }
 
 func itoa(n int) string {
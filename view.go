@@ -5,44 +5,97 @@ import (
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 	"github.com/mattn/go-runewidth"
 )
 
+// styles are built from the configured colors by applyTheme
 var (
+	borderStyle     lipgloss.Style
+	titleStyle      lipgloss.Style
+	cursorStyle     lipgloss.Style
+	visualStyle     lipgloss.Style
+	statusStyle     lipgloss.Style
+	helpStyle       lipgloss.Style
+	liveStyle       lipgloss.Style
+	searchStyle     lipgloss.Style
+	overlayStyle    lipgloss.Style
+	headerStyle     lipgloss.Style // capture block headers
+	markSymbol      string
+	noteMarkSymbol  string
+	rangeSymbol     string // continuation of a range mark
+	noteRangeSymbol string // continuation of a range mark with a note
+)
+
+func init() {
+	applyTheme(defaultConfig().Colors)
+}
+
+// applyTheme rebuilds all styles from the given colors
+func applyTheme(c ColorsConfig) {
 	borderStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("62"))
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(c.Accent))
 
 	titleStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("62"))
+		Bold(true).
+		Foreground(lipgloss.Color(c.Accent))
 
 	cursorStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("212"))
+		Bold(true).
+		Foreground(lipgloss.Color(c.Cursor))
 
 	markSymbol = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("212")).
-			Render("●")
+		Foreground(lipgloss.Color(c.Mark)).
+		Render("●")
 
 	noteMarkSymbol = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("220")).
-				Render("●")
+		Foreground(lipgloss.Color(c.NoteMark)).
+		Render("●")
+
+	rangeSymbol = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(c.Mark)).
+		Render("│")
+
+	noteRangeSymbol = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(c.NoteMark)).
+		Render("│")
+
+	visualStyle = lipgloss.NewStyle().
+		Background(lipgloss.Color(c.Visual))
 
 	statusStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("241"))
+		Foreground(lipgloss.Color(c.Status))
 
 	helpStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("241")).
-			Italic(true)
-)
+		Foreground(lipgloss.Color(c.Status)).
+		Italic(true)
+
+	headerStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(c.Accent)).
+		Faint(true)
+
+	liveStyle = lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color(c.Live))
+
+	searchStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("0")).
+		Background(lipgloss.Color(c.Search))
+
+	overlayStyle = lipgloss.NewStyle().
+		Padding(1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(c.Accent)).
+		Background(lipgloss.Color(c.Overlay))
+}
 
 func (m Model) View() string {
 	if !m.ready {
 		return "Loading..."
 	}
 
-	leftWidth := m.width*m.splitRatio/100 - 2
+	leftWidth := m.contentWidth()
 	rightWidth := m.width - leftWidth - 4
 	statusHeight := 1
 	if m.inputMode {
@@ -75,14 +128,37 @@ func (m Model) View() string {
 	// bottom status bar
 	var statusBar string
 	if m.inputMode {
-		hint := statusStyle.Render(fmt.Sprintf("  Note L%d  (Ctrl+S submit | Esc cancel)", m.cursorLine+1))
+		label := fmt.Sprintf("L%d", m.cursorLine+1)
+		if m.noteMatches {
+			label = fmt.Sprintf("%d matches of /%s", len(m.searchMatches), m.searchPattern)
+		} else if mk := m.GetMark(m.cursorLine); mk != nil {
+			label = mk.Label()
+		}
+		hint := statusStyle.Render(fmt.Sprintf("  Note %s  (%s submit | %s cancel)",
+			label, firstKey(keys.SubmitNote), firstKey(keys.Escape)))
 		statusBar = hint + "\n" + m.noteInput.View()
+	} else if m.visualMode {
+		start, end := m.visualRange()
+		statusBar = statusStyle.Render(fmt.Sprintf("  -- VISUAL -- L%d-%d  (%s mark | %s mark+note | %s cancel)",
+			start+1, end+1, firstKey(keys.Mark), firstKey(keys.Comment), firstKey(keys.Escape)))
+	} else if m.searchInput {
+		prompt := firstKey(keys.Search)
+		if m.searchBackward {
+			prompt = firstKey(keys.SearchBack)
+		}
+		count := ""
+		if m.searchBuf != "" {
+			count = fmt.Sprintf("  [%d matches]", len(m.searchMatches))
+		}
+		statusBar = statusStyle.Render(fmt.Sprintf("  %s%s█%s  (%s confirm | %s cancel)",
+			prompt, m.searchBuf, count, firstKey(keys.Confirm), firstKey(keys.Escape)))
 	} else if m.captureInput {
 		input := m.captureInputBuf
 		if input == "" {
 			input = "(empty = full scrollback)"
 		}
-		statusBar = statusStyle.Render(fmt.Sprintf("  Lines: %s  (Enter confirm | Esc cancel)", input))
+		statusBar = statusStyle.Render(fmt.Sprintf("  Lines: %s  (%s confirm | %s cancel)",
+			input, firstKey(keys.Confirm), firstKey(keys.Escape)))
 	} else if m.statusMsg != "" {
 		statusBar = statusStyle.Render(fmt.Sprintf("  %s", m.statusMsg))
 	} else {
@@ -100,48 +176,122 @@ func (m Model) View() string {
 
 func (m Model) renderContent(width, height int) string {
 	if len(m.lines) == 0 {
-		return helpStyle.Render("Press r to capture left pane content")
+		return helpStyle.Render(fmt.Sprintf("Press %s to capture left pane content", firstKey(keys.Capture)))
 	}
 
-	// ensure scrollOffset keeps cursor within visible range
-	start := m.scrollOffset
-	if start > m.cursorLine {
-		start = m.cursorLine
-	}
-	if m.cursorLine >= start+height {
-		start = m.cursorLine - height + 1
-	}
-	if start < 0 {
-		start = 0
+	visStart, visEnd := m.visualRange()
+
+	var rows []string
+	for i := m.viewTop(height); i < len(m.lines) && len(rows) < height; i = m.nextShown(i) {
+		if m.isHeader(i) {
+			rows = append(rows, m.renderHeader(i, width))
+			continue
+		}
+		rows = append(rows, m.renderLine(i, width, visStart, visEnd)...)
 	}
-	end := start + height
-	if end > len(m.lines) {
-		end = len(m.lines)
+	return strings.Join(rows[:min(len(rows), height)], "\n")
+}
+
+// renderLine renders content line i: one row, cut to the panel width and
+// shifted by the horizontal scroll, or in wrap mode as many rows as it needs
+func (m Model) renderLine(i, width, visStart, visEnd int) []string {
+	textWidth := width - 8
+	var plainRows, styledRows []string
+	if m.wrap {
+		for k := range m.lineRows(i) {
+			plainRows = append(plainRows, cutCells(m.lines[i], k*textWidth, (k+1)*textWidth))
+			styledRows = append(styledRows, cutCells(m.styledLine(i), k*textWidth, (k+1)*textWidth))
+		}
+	} else {
+		plainRows = []string{truncateLine(ansi.TruncateLeft(m.lines[i], m.hscroll, ""), textWidth)}
+		styledRows = []string{truncateLine(ansi.TruncateLeft(m.styledLine(i), m.hscroll, ""), textWidth)}
 	}
 
-	var lines []string
-	for i := start; i < end; i++ {
+	var rows []string
+	for k := range plainRows {
+		// wrapped continuation rows carry no line number
 		lineNum := fmt.Sprintf("%4d", i+1)
+		if k > 0 {
+			lineNum = "    "
+		}
 		mark := "  "
 		if mk := m.GetMark(i); mk != nil {
-			if mk.Note != "" {
+			switch {
+			case (i != mk.Line || k > 0) && mk.Note != "":
+				mark = noteRangeSymbol + " "
+			case i != mk.Line || k > 0:
+				mark = rangeSymbol + " "
+			case mk.Note != "":
 				mark = noteMarkSymbol + " "
-			} else {
+			default:
 				mark = markSymbol + " "
 			}
 		}
 
-		lineText := truncateLine(m.lines[i], width-8)
+		// styles from the pane would break the highlight, so highlighted rows
+		// and rows with search matches use plain text
+		lineText := plainRows[k]
+		matched := m.searchRe != nil && m.searchRe.MatchString(lineText)
+		highlighted := i == m.cursorLine || (m.visualMode && i >= visStart && i <= visEnd)
+		if !highlighted && !matched {
+			lineText = styledRows[k]
+		}
+
+		base := lipgloss.NewStyle()
+		prefix := " "
+		if m.visualMode && i >= visStart && i <= visEnd {
+			base = visualStyle
+			if i == m.cursorLine {
+				prefix = "▶"
+			}
+		} else if i == m.cursorLine {
+			base = cursorStyle
+			prefix = "▶"
+		}
+		if k > 0 {
+			prefix = " "
+		}
 
-		if i == m.cursorLine {
-			line := cursorStyle.Render(fmt.Sprintf("▶%s %s%s", lineNum, mark, lineText))
-			lines = append(lines, line)
-		} else {
-			line := fmt.Sprintf(" %s %s%s", lineNum, mark, lineText)
-			lines = append(lines, line)
+		gutter := fmt.Sprintf("%s%s %s", prefix, lineNum, mark)
+		if highlighted {
+			gutter = base.Render(gutter)
+		}
+		if matched {
+			lineText = highlightMatches(lineText, m.searchRe, base)
+		} else if highlighted {
+			lineText = base.Render(lineText)
 		}
+		rows = append(rows, gutter+lineText)
 	}
-	return strings.Join(lines, "\n")
+	return rows
+}
+
+// cutCells returns display cells from..to of a line, keeping the styles
+// in effect and closing them at the end
+func cutCells(s string, from, to int) string {
+	if !hasStyle(s) {
+		return ansi.Cut(s, from, to)
+	}
+	return ansi.Cut(s, from, to) + sgrReset
+}
+
+// renderHeader renders a block header. Headers carry no line number; a
+// folded block's header shows how many lines it hides instead, and can hold
+// the cursor.
+func (m Model) renderHeader(line, width int) string {
+	i := blockIndexIn(m.captures, line)
+	prefix, count := " ", ""
+	if m.captures[i].Folded {
+		first, last := m.blockSpan(i)
+		prefix, count = "▸", "+"+itoa(last-first+1)
+	}
+	style := headerStyle
+	if line == m.cursorLine {
+		prefix, style = "▶", cursorStyle
+	}
+	gutter := fmt.Sprintf("%s%6s ", prefix, count)
+	text := truncateLine(m.lines[line], width-8)
+	return style.Render(gutter + text)
 }
 
 func (m Model) renderMarks(width, height int) string {
@@ -149,25 +299,45 @@ func (m Model) renderMarks(width, height int) string {
 	lines := []string{title, ""}
 
 	for _, mk := range m.marks {
-		entry := fmt.Sprintf("L%d", mk.Line+1)
+		entry := mk.Label()
 		if mk.Note != "" {
-			entry += " " + truncateLine(mk.Note, width-8)
+			entry += " " + truncateLine(mk.Note, width-len(entry)-2)
 		} else {
-			entry += " " + truncateLine(mk.Text, width-8)
+			firstLine, _, _ := strings.Cut(mk.Text, "\n")
+			entry += " " + truncateLine(firstLine, width-len(entry)-2)
 		}
 		lines = append(lines, entry)
 	}
 
 	if len(m.marks) == 0 {
-		lines = append(lines, helpStyle.Render("Press m to mark a line"))
+		lines = append(lines, helpStyle.Render(fmt.Sprintf("Press %s to mark a line", firstKey(keys.Mark))))
 	}
 
 	return strings.Join(lines, "\n")
 }
 
 func (m Model) renderStatusBar() string {
-	leftText := "  ? help | q quit | m mark | S export"
-	right := statusStyle.Render(fmt.Sprintf("L%d/%d  Marks: %d  ", m.cursorLine+1, len(m.lines), len(m.marks)))
+	leftText := fmt.Sprintf("  %s help | %s quit | %s mark | %s export",
+		firstKey(keys.Help), firstKey(keys.Quit), firstKey(keys.Mark), firstKey(keys.Submit))
+	right := statusStyle.Render(fmt.Sprintf("L%d/%d  Marks: %d  [%s]  ", m.cursorLine+1, len(m.lines), len(m.marks), m.exporter().Name()))
+	if m.searchRe != nil {
+		pos := "-"
+		if i := m.matchPosition(); i > 0 {
+			pos = itoa(i)
+		}
+		right = statusStyle.Render(fmt.Sprintf("/%s [%s/%d]  ", m.searchPattern, pos, len(m.searchMatches))) + right
+	}
+	if m.wrap {
+		right = statusStyle.Render("wrap  ") + right
+	} else if m.hscroll > 0 {
+		right = statusStyle.Render(fmt.Sprintf("col %d+  ", m.hscroll+1)) + right
+	}
+	if src := m.sourcePane(); src != m.watchedPane() {
+		right = statusStyle.Render("⇄ "+src+"  ") + right
+	}
+	if live := m.liveIndicator(); live != "" {
+		right = live + " " + right
+	}
 
 	rightW := lipgloss.Width(right)
 	maxLeft := m.width - rightW
@@ -183,32 +353,29 @@ func (m Model) renderStatusBar() string {
 	return left + strings.Repeat(" ", gap) + right
 }
 
+// liveIndicator shows follow mode state in the status bar
+func (m Model) liveIndicator() string {
+	switch {
+	case !m.following:
+		return ""
+	case m.followPaused():
+		return statusStyle.Render("⏸ paused")
+	default:
+		return liveStyle.Render("● LIVE")
+	}
+}
+
 func (m Model) overlayContent() string {
 	switch m.overlayType {
 	case overlayHelp:
-		return `clipnote shortcuts
-
-r         capture visible area
-R         custom range capture
-Ctrl+r    clear all content
-j/k ↑/↓   move cursor
-g / G     top / bottom
-m         toggle mark
-c         mark + note
-v         view note
-S         export to clipboard
-P         paste to left pane
-[ / ]     resize panels
-q         quit
-?         this help
-
-press any key to close...`
+		return "clipnote shortcuts\n\n" + strings.Join(keys.helpLines(""), "\n") + "\n\npress any key to close..."
+
+	case overlayPanes:
+		return m.pickerContent()
 
 	case overlayNote:
-		for _, mk := range m.marks {
-			if mk.Line == m.cursorLine && mk.Note != "" {
-				return fmt.Sprintf("Note on L%d\n\n%s\n\npress any key to close...", mk.Line+1, mk.Note)
-			}
+		if mk := m.GetMark(m.cursorLine); mk != nil && mk.Note != "" {
+			return fmt.Sprintf("Note on %s\n\n%s\n\npress any key to close...", mk.Label(), mk.Note)
 		}
 	}
 	return ""
@@ -239,13 +406,9 @@ func renderOverlay(base, content string, width, height int) string {
 		boxH = height - 2
 	}
 
-	overlay := lipgloss.NewStyle().
+	overlay := overlayStyle.
 		Width(boxInnerW).
 		Height(boxH).
-		Padding(1).
-		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("62")).
-		Background(lipgloss.Color("235")).
 		Render(content)
 
 	overlayLines := strings.Split(overlay, "\n")
@@ -319,6 +482,13 @@ func truncateLine(s string, maxWidth int) string {
 	if maxWidth <= 0 {
 		return ""
 	}
+	if hasStyle(s) {
+		// escape sequences take no cells; cut by display width and close open styles
+		if ansi.StringWidth(s) <= maxWidth {
+			return s
+		}
+		return ansi.Truncate(s, maxWidth, "…") + sgrReset
+	}
 	if runewidth.StringWidth(s) <= maxWidth {
 		return s
 	}